@@ -1,6 +1,9 @@
 kubectl create -f deploy/crds/example.com_wordpresses_crd.yaml
+kubectl create -f deploy/crds/example.com_wordpresssnapshots_crd.yaml
+kubectl create -f deploy/crds/example.com_wordpresspromotions_crd.yaml
 kubectl create -f deploy/role.yaml
 kubectl create -f deploy/role_binding.yaml
 kubectl create -f deploy/service_account.yaml
+kubectl create -f deploy/webhook.yaml
 kubectl create -f deploy/operator.yaml
 kubectl create -f wordpress.yaml
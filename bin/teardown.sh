@@ -1,5 +1,6 @@
 kubectl delete -f wordpress.yaml
 kubectl delete -f deploy/operator.yaml
+kubectl delete -f deploy/webhook.yaml
 kubectl delete -f deploy/role.yaml
 kubectl delete -f deploy/role_binding.yaml
 kubectl delete -f deploy/service_account.yaml
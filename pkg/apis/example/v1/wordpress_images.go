@@ -0,0 +1,33 @@
+package v1
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ImagePullSecrets returns the secrets named in operatorSecrets, the comma
+// separated secrets from the operator configuration, followed by secrets
+func ImagePullSecrets(operatorSecrets string, secrets []corev1.LocalObjectReference) []corev1.LocalObjectReference {
+	all := []corev1.LocalObjectReference{}
+	for _, name := range strings.Split(operatorSecrets, ",") {
+		all = append(all, corev1.LocalObjectReference{Name: strings.TrimSpace(name)})
+	}
+	all = append(all, secrets...)
+
+	var pullSecrets []corev1.LocalObjectReference
+	seen := map[string]bool{}
+	for _, secret := range all {
+		if secret.Name == "" || seen[secret.Name] {
+			continue
+		}
+		seen[secret.Name] = true
+		pullSecrets = append(pullSecrets, secret)
+	}
+	return pullSecrets
+}
+
+// ImagePullSecrets returns the operator wide secrets followed by spec.imagePullSecrets
+func (w *Wordpress) ImagePullSecrets(operatorSecrets string) []corev1.LocalObjectReference {
+	return ImagePullSecrets(operatorSecrets, w.Spec.ImagePullSecrets)
+}
@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// podSelectorLabels are the pod labels the Deployments select their pods by
+var podSelectorLabels = []string{"app", "tier"}
+
+// ApplyPodTemplatePatch applies patch as a strategic merge patch over
+// template, failing on fields unknown to a PodTemplateSpec
+func ApplyPodTemplatePatch(template *corev1.PodTemplateSpec, patch *runtime.RawExtension) error {
+	if patch == nil || len(patch.Raw) == 0 {
+		return nil
+	}
+
+	original, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch.Raw, corev1.PodTemplateSpec{})
+	if err != nil {
+		return fmt.Errorf("invalid pod template patch: %v", err)
+	}
+
+	result := corev1.PodTemplateSpec{}
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return fmt.Errorf("invalid pod template patch: %v", err)
+	}
+	*template = result
+	return nil
+}
+
+// ValidatePodTemplatePatch returns an error if patch is not a valid strategic
+// merge patch of a PodTemplateSpec, or may change the labels selecting the
+// pods. The generated template is not known here, so any mention of those
+// labels is rejected, including null values deleting them, and so are null
+// metadata or labels and $patch directives replacing them.
+func ValidatePodTemplatePatch(field string, patch *runtime.RawExtension) error {
+	template := &corev1.PodTemplateSpec{}
+	if err := ApplyPodTemplatePatch(template, patch); err != nil {
+		return fmt.Errorf("%s: %v", field, err)
+	}
+	if patch == nil || len(patch.Raw) == 0 {
+		return nil
+	}
+
+	replaced := fmt.Errorf("%s must not replace or remove the pod labels, the app and tier labels select the pods of the Deployment", field)
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(patch.Raw, &object); err != nil {
+		return fmt.Errorf("%s: invalid pod template patch: %v", field, err)
+	}
+	if hasDirective(object) {
+		return replaced
+	}
+	value, ok := object["metadata"]
+	if !ok {
+		return nil
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok || hasDirective(metadata) {
+		return replaced
+	}
+	value, ok = metadata["labels"]
+	if !ok {
+		return nil
+	}
+	labels, ok := value.(map[string]interface{})
+	if !ok || hasDirective(labels) {
+		return replaced
+	}
+	for _, label := range podSelectorLabels {
+		if _, ok := labels[label]; ok {
+			return fmt.Errorf("%s must not set the %s label, it selects the pods of the Deployment", field, label)
+		}
+	}
+	return nil
+}
+
+// returns true if a strategic merge patch object has a directive, like
+// $patch: replace, changing how its keys are merged
+func hasDirective(object map[string]interface{}) bool {
+	for key := range object {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
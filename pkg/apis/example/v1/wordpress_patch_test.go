@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func testPodTemplate() *corev1.PodTemplateSpec {
+	return &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "wordpress", "tier": "frontend"}},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "wordpress",
+				Image: "wordpress:4.8-apache",
+				Env:   []corev1.EnvVar{{Name: "WORDPRESS_DB_HOST", Value: "wordpress-mysql"}},
+			}},
+			Volumes: []corev1.Volume{
+				{Name: "wordpress-persistent-storage"},
+				{Name: "cache"},
+			},
+		},
+	}
+}
+
+func TestApplyPodTemplatePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   *runtime.RawExtension
+		want    func(*corev1.PodTemplateSpec)
+		wantErr string
+	}{
+		{name: "no patch"},
+		{name: "empty patch", patch: &runtime.RawExtension{}},
+		{
+			name:  "adds an annotation",
+			patch: &runtime.RawExtension{Raw: []byte(`{"metadata":{"annotations":{"example.com/scrape":"true"}}}`)},
+			want: func(template *corev1.PodTemplateSpec) {
+				template.Annotations = map[string]string{"example.com/scrape": "true"}
+			},
+		},
+		{
+			name:  "merges a container by name",
+			patch: &runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"wordpress","env":[{"name":"WP_DEBUG","value":"1"}]}]}}`)},
+			want: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers[0].Env = []corev1.EnvVar{
+					{Name: "WP_DEBUG", Value: "1"},
+					{Name: "WORDPRESS_DB_HOST", Value: "wordpress-mysql"},
+				}
+			},
+		},
+		{
+			name:  "adds a sidecar",
+			patch: &runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"exporter","image":"exporter:1.0"}]}}`)},
+			want: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers = append([]corev1.Container{{Name: "exporter", Image: "exporter:1.0"}}, template.Spec.Containers...)
+			},
+		},
+		{
+			name:  "deletes a volume",
+			patch: &runtime.RawExtension{Raw: []byte(`{"spec":{"volumes":[{"name":"cache","$patch":"delete"}]}}`)},
+			want: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Volumes = template.Spec.Volumes[:1]
+			},
+		},
+		{
+			name:    "unknown field",
+			patch:   &runtime.RawExtension{Raw: []byte(`{"spec":{"containerz":[]}}`)},
+			wantErr: "invalid pod template patch",
+		},
+		{
+			name:    "invalid JSON",
+			patch:   &runtime.RawExtension{Raw: []byte(`{"spec":`)},
+			wantErr: "invalid pod template patch",
+		},
+		{
+			name:    "wrong type",
+			patch:   &runtime.RawExtension{Raw: []byte(`{"spec":{"hostNetwork":"yes"}}`)},
+			wantErr: "invalid pod template patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := testPodTemplate()
+			want := testPodTemplate()
+			if tt.want != nil {
+				tt.want(want)
+			}
+
+			err := ApplyPodTemplatePatch(template, tt.patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyPodTemplatePatch() error = %v, want %q", err, tt.wantErr)
+				}
+				// a failed patch leaves the template as is
+				want = testPodTemplate()
+			} else if err != nil {
+				t.Fatalf("ApplyPodTemplatePatch() error = %v", err)
+			}
+			if !reflect.DeepEqual(template, want) {
+				t.Errorf("ApplyPodTemplatePatch() = %+v, want %+v", template, want)
+			}
+		})
+	}
+}
+
+func TestValidatePodTemplatePatch(t *testing.T) {
+	field := "spec.wordpress.podTemplatePatch"
+
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr string
+	}{
+		{name: "no patch"},
+		{name: "labels and annotations", patch: `{"metadata":{"labels":{"team":"web"},"annotations":{"a":"b"}}}`},
+		{name: "pod spec", patch: `{"spec":{"hostAliases":[{"ip":"10.0.0.1","hostnames":["db"]}]}}`},
+		{
+			name:    "app label",
+			patch:   `{"metadata":{"labels":{"app":"blog"}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not set the app label, it selects the pods of the Deployment",
+		},
+		{
+			name:    "tier label",
+			patch:   `{"metadata":{"labels":{"tier":"web"}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not set the tier label, it selects the pods of the Deployment",
+		},
+		{
+			name:    "app label removed",
+			patch:   `{"metadata":{"labels":{"app":null}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not set the app label, it selects the pods of the Deployment",
+		},
+		{
+			name:    "tier label removed",
+			patch:   `{"metadata":{"labels":{"team":"web","tier":null}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not set the tier label, it selects the pods of the Deployment",
+		},
+		{
+			name:    "labels replaced",
+			patch:   `{"metadata":{"labels":{"$patch":"replace","team":"web"}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not replace or remove the pod labels",
+		},
+		{
+			name:    "labels removed",
+			patch:   `{"metadata":{"labels":null}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not replace or remove the pod labels",
+		},
+		{
+			name:    "metadata replaced",
+			patch:   `{"metadata":{"$patch":"replace","annotations":{"a":"b"}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not replace or remove the pod labels",
+		},
+		{
+			name:    "metadata removed",
+			patch:   `{"metadata":null}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not replace or remove the pod labels",
+		},
+		{
+			name:    "template replaced",
+			patch:   `{"$patch":"replace","spec":{"containers":[{"name":"wordpress","image":"wordpress"}]}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not replace or remove the pod labels",
+		},
+		{
+			name:    "unknown field",
+			patch:   `{"spec":{"containerz":[]}}`,
+			wantErr: "spec.wordpress.podTemplatePatch: invalid pod template patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch *runtime.RawExtension
+			if tt.patch != "" {
+				patch = &runtime.RawExtension{Raw: []byte(tt.patch)}
+			}
+			err := ValidatePodTemplatePatch(field, patch)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePodTemplatePatch() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePodTemplatePatch() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// WordpressPreset is a named size for the resources of the mysql and wordpress
+// containers, defined in the operator configuration
+type WordpressPreset struct {
+	// Resources of the mysql container
+	Mysql corev1.ResourceRequirements `json:"mysql,omitempty"`
+
+	// Resources of the wordpress container
+	Wordpress corev1.ResourceRequirements `json:"wordpress,omitempty"`
+}
+
+// ParsePresets parses the JSON object of presets by name from the operator configuration
+func ParsePresets(presets string) (map[string]WordpressPreset, error) {
+	parsed := map[string]WordpressPreset{}
+	if presets == "" {
+		return parsed, nil
+	}
+	if err := json.Unmarshal([]byte(presets), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid presets configuration: %v", err)
+	}
+	return parsed, nil
+}
+
+// PresetName returns spec.preset, or defaultPreset if not set
+func (w *Wordpress) PresetName(defaultPreset string) string {
+	if w.Spec.Preset != "" {
+		return w.Spec.Preset
+	}
+	return defaultPreset
+}
+
+// ValidatePreset returns an error if the preset of w is not defined in presets
+func (w *Wordpress) ValidatePreset(presets string, defaultPreset string) error {
+	name := w.PresetName(defaultPreset)
+	if name == "" {
+		return nil
+	}
+
+	parsed, err := ParsePresets(presets)
+	if err != nil {
+		return err
+	}
+	if _, ok := parsed[name]; !ok {
+		return fmt.Errorf("preset %q is not defined in the operator configuration", name)
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package v1
 
 import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"github.com/operator-framework/operator-sdk/pkg/status"
 )
 
@@ -12,19 +16,439 @@ type WordpressSpec struct {
 
 	// Set to true to retain volumes and don't delete PVCs for the Mysql and Wordpress Deployments
 	RetainVolumes   bool `json:"retainVolumes,omitempty"`
+
+	// What the finalizer does before deleting the PVCs when retainVolumes is false: Delete (default) deletes them,
+	// Backup first takes a final WordpressSnapshot and deletes them once it is Ready
+	DeletionPolicy WordpressDeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Time the finalizer waits for the final WordpressSnapshot of deletionPolicy Backup, defaults to 30m
+	DeletionBackupTimeout *metav1.Duration `json:"deletionBackupTimeout,omitempty"`
+
+	// Set to true to reject deletion of this Wordpress and its PVCs by the admission webhook
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
+	// Name of a Ready WordpressSnapshot in the same namespace to populate newly created PVCs from
+	FromSnapshot string `json:"fromSnapshot,omitempty"`
+
+	// Wordpress instance in another namespace to copy the database and wp-content from when this instance is created
+	CloneFrom *WordpressCloneSource `json:"cloneFrom,omitempty"`
+
+	// Namespaces allowed to clone this instance with spec.cloneFrom or to promote it with a WordpressPromotion,
+	// none by default
+	AllowedCloneNamespaces []string `json:"allowedCloneNamespaces,omitempty"`
+
+	// Configuration of the Service exposing wordpress, defaults to a LoadBalancer on port 80
+	Service *WordpressServiceSpec `json:"service,omitempty"`
+
+	// Ingress routing hostnames to wordpress, the first host is the site URL
+	Ingress *WordpressIngressSpec `json:"ingress,omitempty"`
+
+	// Gateway API HTTPRoute routing hostnames to wordpress, an alternative to spec.ingress
+	Gateway *WordpressGatewaySpec `json:"gateway,omitempty"`
+
+	// cert-manager Certificate for the hostnames of spec.ingress and spec.gateway
+	TLS *WordpressTLSSpec `json:"tls,omitempty"`
+
+	// NetworkPolicies isolating mysql and wordpress
+	NetworkPolicy *WordpressNetworkPolicySpec `json:"networkPolicy,omitempty"`
+
+	// Size preset for the resources of the mysql and wordpress containers, e.g. small, medium or large
+	Preset string `json:"preset,omitempty"`
+
+	// Secrets for pulling the images of the pods, in addition to WORDPRESS_IMAGE_PULL_SECRETS
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Set to true to resolve the mysql and wordpress images to digests once and pin the Deployments to them
+	PinImageDigests bool `json:"pinImageDigests,omitempty"`
+
+	// ServiceAccount the mysql and wordpress pods run as, e.g. with workload identity annotations
+	ServiceAccount *WordpressServiceAccountSpec `json:"serviceAccount,omitempty"`
+
+	// Configuration of the wordpress Deployment
+	Wordpress *WordpressFrontendSpec `json:"wordpress,omitempty"`
+
+	// Configuration of the mysql Deployment
+	Mysql *WordpressMysqlSpec `json:"mysql,omitempty"`
+}
+
+// WordpressDeletionPolicy is what the finalizer does before deleting the PVCs
+// +kubebuilder:validation:Enum=Delete;Backup
+type WordpressDeletionPolicy string
+
+const (
+	// WordpressDeletionPolicyDelete: delete the PVCs
+	WordpressDeletionPolicyDelete WordpressDeletionPolicy = "Delete"
+	// WordpressDeletionPolicyBackup: delete the PVCs once a final WordpressSnapshot is Ready
+	WordpressDeletionPolicyBackup WordpressDeletionPolicy = "Backup"
+)
+
+// WordpressMysqlSpec configures the mysql Deployment
+type WordpressMysqlSpec struct {
+	// Scheduling of the mysql pod
+	WordpressSchedulingSpec `json:",inline"`
+
+	// Resources of the mysql container, override the resources of the preset
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// PodDisruptionBudget of the mysql pod, none by default
+	PodDisruptionBudget *WordpressPodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+
+	// Liveness probe of the mysql container, replaces the default mysqladmin ping
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// Readiness probe of the mysql container, replaces the default mysqladmin ping
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+
+	// Startup probe of the mysql container, replaces the default mysqladmin ping
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// Strategic merge patch applied over the generated pod template of the mysql Deployment,
+	// e.g. to add sidecars, volumes or env vars
+	PodTemplatePatch *runtime.RawExtension `json:"podTemplatePatch,omitempty"`
+
+	// Security context of the mysql pod, replaces the default running as the non-root mysql user with the RuntimeDefault seccomp profile
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// Security context of the mysql container, replaces the default dropping all capabilities with a read-only root filesystem
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// WordpressSchedulingSpec configures where the pods of a Deployment are scheduled
+type WordpressSchedulingSpec struct {
+	// Labels of the nodes the pods may be scheduled on
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Node and pod affinity of the pods, replaces the default pod anti-affinity if it sets one
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Tolerations of the pods for node taints
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Spread of the pods across topology domains, e.g. zones
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// PriorityClass of the pods
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// WordpressPodDisruptionBudgetSpec overrides the PodDisruptionBudget of a Deployment
+type WordpressPodDisruptionBudgetSpec struct {
+	// Number or percentage of pods that must remain available during voluntary disruptions
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// Number or percentage of pods that may be unavailable during voluntary disruptions
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// Set to true to not create the PodDisruptionBudget
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// WordpressFrontendSpec configures the wordpress Deployment
+type WordpressFrontendSpec struct {
+	// Scheduling of the wordpress pods, by default preferring separate nodes
+	WordpressSchedulingSpec `json:",inline"`
+
+	// Number of wordpress pods, defaults to 1. More than 1 requires a ReadWriteMany storage class or mediaOffload
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// StorageClass of the wordpress PVC, which is ReadWriteMany if the class is listed in WORDPRESS_RWX_STORAGE_CLASSES
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Set to true if uploads are offloaded to object storage and themes and plugins are part of the image,
+	// the wordpress pods then don't mount the wordpress PVC
+	MediaOffload bool `json:"mediaOffload,omitempty"`
+
+	// HorizontalPodAutoscaler for the wordpress Deployment, replaces Replicas
+	Autoscaling *WordpressAutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// PodDisruptionBudget of the wordpress pods, defaults to maxUnavailable 1 with more than one replica
+	PodDisruptionBudget *WordpressPodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+
+	// Resources of the wordpress container, override the resources of the preset
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Liveness probe of the wordpress container, replaces the default HTTP GET
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// Readiness probe of the wordpress container, replaces the default HTTP GET
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+
+	// Startup probe of the wordpress container, replaces the default HTTP GET
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// Strategic merge patch applied over the generated pod template of the wordpress Deployment,
+	// e.g. to add sidecars, volumes or env vars
+	PodTemplatePatch *runtime.RawExtension `json:"podTemplatePatch,omitempty"`
+
+	// Security context of the wordpress pod, replaces the default only setting the RuntimeDefault seccomp profile as apache starts as root
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// Security context of the wordpress container, replaces the default dropping the capabilities apache doesn't need with a read-only root filesystem
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// WordpressAutoscalingSpec configures the HorizontalPodAutoscaler of the wordpress Deployment
+type WordpressAutoscalingSpec struct {
+	// Lower limit of wordpress pods, defaults to 1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// Upper limit of wordpress pods, more than 1 requires a ReadWriteMany storage class or mediaOffload
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// Target average CPU utilization of the wordpress pods, in percent of the requested CPU
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+
+	// Target average memory utilization of the wordpress pods, in percent of the requested memory
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+
+	// Additional metrics, e.g. custom or external metrics, as autoscaling/v2 MetricSpecs
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+}
+
+// WordpressServiceSpec configures the Service exposing wordpress
+type WordpressServiceSpec struct {
+	// Service type: ClusterIP, NodePort or LoadBalancer, defaults to LoadBalancer
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Port the Service exposes wordpress on, defaults to 80
+	Port int32 `json:"port,omitempty"`
+
+	// Node port for NodePort and LoadBalancer Services, allocated by Kubernetes if not set
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// Client CIDRs allowed to access a LoadBalancer Service
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// Cluster or Local, for NodePort and LoadBalancer Services
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+
+	// Annotations added to the Service, e.g. to configure the cloud load balancer
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// WordpressIngressSpec configures the Ingress for wordpress
+type WordpressIngressSpec struct {
+	// Hostnames routed to wordpress, the first host is used for WP_HOME and WP_SITEURL
+	Hosts []string `json:"hosts"`
+
+	// Paths routed to wordpress for each host, defaults to /
+	Paths []string `json:"paths,omitempty"`
+
+	// Ingress class of the ingress controller serving the Ingress
+	IngressClassName string `json:"ingressClassName,omitempty"`
+
+	// Annotations added to the Ingress, e.g. to configure the ingress controller
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Name of an existing Secret with the TLS certificate for the hosts, enables https, ignored if spec.tls is set
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+}
+
+// WordpressGatewaySpec configures the Gateway API HTTPRoute for wordpress
+type WordpressGatewaySpec struct {
+	// Gateway the HTTPRoute is attached to
+	GatewayRef WordpressGatewayReference `json:"gatewayRef"`
+
+	// Hostnames matched by the HTTPRoute, the first hostname is the site URL if spec.ingress is not set
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	// Path prefixes routed to wordpress, defaults to /
+	Paths []string `json:"paths,omitempty"`
+
+	// Headers a request must have to be routed to wordpress
+	Headers []WordpressHeaderMatch `json:"headers,omitempty"`
+}
+
+// WordpressGatewayReference references a Gateway
+type WordpressGatewayReference struct {
+	// Name of the Gateway
+	Name string `json:"name"`
+
+	// Namespace of the Gateway, defaults to the namespace of the Wordpress instance
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the Gateway listener to attach to, all listeners if not set
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// WordpressHeaderMatch matches a request header exactly
+type WordpressHeaderMatch struct {
+	// Name of the header
+	Name string `json:"name"`
+
+	// Value of the header
+	Value string `json:"value"`
+}
+
+// WordpressTLSSpec configures the cert-manager Certificate for wordpress
+type WordpressTLSSpec struct {
+	// cert-manager Issuer or ClusterIssuer issuing the Certificate
+	IssuerRef WordpressIssuerReference `json:"issuerRef"`
+
+	// Name of the Secret the certificate is stored in, defaults to wordpress-tls
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// WordpressIssuerReference references a cert-manager issuer
+type WordpressIssuerReference struct {
+	// Name of the issuer
+	Name string `json:"name"`
+
+	// Issuer or ClusterIssuer, defaults to Issuer
+	Kind string `json:"kind,omitempty"`
+
+	// API group of the issuer, defaults to cert-manager.io
+	Group string `json:"group,omitempty"`
+}
+
+// WordpressTLSStatus reports the Certificate issued for spec.tls
+type WordpressTLSStatus struct {
+	// The certificate is issued and valid
+	Ready bool `json:"ready"`
+
+	// Expiry of the certificate
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// Time cert-manager will renew the certificate
+	RenewalTime *metav1.Time `json:"renewalTime,omitempty"`
+
+	// Message of the Ready condition of the Certificate
+	Message string `json:"message,omitempty"`
+}
+
+// WordpressServiceAccountSpec configures the ServiceAccount of the pods
+type WordpressServiceAccountSpec struct {
+	// Annotations of the ServiceAccount, e.g. iam.gke.io/gcp-service-account or eks.amazonaws.com/role-arn
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Set to true to mount the ServiceAccount token in the pods
+	AutomountServiceAccountToken bool `json:"automountServiceAccountToken,omitempty"`
+}
+
+// WordpressImagesStatus reports the images of the mysql and wordpress containers
+type WordpressImagesStatus struct {
+	// Image of the mysql container, pinned to its digest
+	Mysql string `json:"mysql,omitempty"`
+
+	// Image of the wordpress container, pinned to its digest
+	Wordpress string `json:"wordpress,omitempty"`
+}
+
+// WordpressPodSecurityStatus reports the Pod Security Standard level the pods meet
+type WordpressPodSecurityStatus struct {
+	// Most restrictive level met by the mysql and wordpress pods: privileged, baseline or restricted
+	Level string `json:"level,omitempty"`
+
+	// Level enforced on the namespace by the pod-security.kubernetes.io/enforce label
+	Enforce string `json:"enforce,omitempty"`
+
+	// Settings of the pods violating the enforced level
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// WordpressNetworkPolicySpec configures the NetworkPolicies for mysql and wordpress
+type WordpressNetworkPolicySpec struct {
+	// Set to true to create the NetworkPolicies
+	Enabled bool `json:"enabled"`
+
+	// Namespaces of the ingress controllers allowed to connect to wordpress, defaults to WORDPRESS_INGRESS_NAMESPACES.
+	// Connections are only restricted to them, and the Gateway namespace, if the Service is ClusterIP, or spec.ingress,
+	// spec.gateway or this field is set; otherwise a NodePort or LoadBalancer Service allows connections from anywhere
+	IngressNamespaces []string `json:"ingressNamespaces,omitempty"`
+
+	// Set to true to only allow mysql to resolve DNS, and wordpress to connect to mysql, DNS and EgressCIDRs
+	RestrictEgress bool `json:"restrictEgress,omitempty"`
+
+	// CIDRs wordpress may connect to when egress is restricted, e.g. for updates and plugins
+	EgressCIDRs []string `json:"egressCIDRs,omitempty"`
+}
+
+// WordpressCloneSource references the Wordpress instance to clone
+type WordpressCloneSource struct {
+	// Name of the source Wordpress instance
+	Name string `json:"name"`
+
+	// Namespace of the source Wordpress instance, must differ from the namespace of the clone
+	Namespace string `json:"namespace"`
+
+	// Site URL of the clone, replaces the source site URL in the cloned database
+	SiteURL string `json:"siteURL"`
+}
+
+// WordpressClonePhase is the progress of cloning a Wordpress instance
+type WordpressClonePhase string
+
+const (
+	// WordpressCloneCloning: the database and wp-content are being copied
+	WordpressCloneCloning WordpressClonePhase = "Cloning"
+	// WordpressCloneCompleted: the clone is complete and the wordpress Deployment is created
+	WordpressCloneCompleted WordpressClonePhase = "Completed"
+	// WordpressCloneFailed: the clone failed, see Message. Failed is final,
+	// recreate the instance to retry
+	WordpressCloneFailed WordpressClonePhase = "Failed"
+)
+
+// WordpressCloneStatus reports the progress of cloning from spec.cloneFrom
+type WordpressCloneStatus struct {
+	// Phase of the clone
+	Phase WordpressClonePhase `json:"phase,omitempty"`
+
+	// The database has been copied and the site URL replaced
+	DatabaseCopied bool `json:"databaseCopied,omitempty"`
+
+	// wp-content has been copied
+	ContentCopied bool `json:"contentCopied,omitempty"`
+
+	// Human readable details about the phase
+	Message string `json:"message,omitempty"`
 }
 
 // WordpressStatus defines the observed state of Wordpress
 type WordpressStatus struct {
     // Conditions: latest available observations of an object's state
     Conditions status.Conditions `json:"conditions"`
+
+    // Progress of cloning from spec.cloneFrom
+    Clone *WordpressCloneStatus `json:"clone,omitempty"`
+
+    // Certificate issued for spec.tls
+    TLS *WordpressTLSStatus `json:"tls,omitempty"`
+
+    // URL the site is reachable at, preferring the Ingress or Gateway hostname
+    URL string `json:"url,omitempty"`
+
+    // All URLs the site is reachable at
+    Endpoints []string `json:"endpoints,omitempty"`
+
+    // Tag of the wordpress image
+    Version string `json:"version,omitempty"`
+
+    // Number of wordpress pods, for the scale subresource
+    Replicas int32 `json:"replicas,omitempty"`
+
+    // Label selector of the wordpress pods, for the scale subresource
+    Selector string `json:"selector,omitempty"`
+
+    // Size preset of the resources of the mysql and wordpress containers
+    Preset string `json:"preset,omitempty"`
+
+    // Pod Security Standard level of the pods, with warnings if the namespace enforces a more restrictive level
+    PodSecurity *WordpressPodSecurityStatus `json:"podSecurity,omitempty"`
+
+    // Images the Deployments are pinned to with spec.pinImageDigests
+    Images *WordpressImagesStatus `json:"images,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // Wordpress is the Schema for the wordpresses API
 // +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.wordpress.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 // +kubebuilder:resource:path=wordpresses,scope=Namespaced
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".status.url"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.version"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:storageversion
 type Wordpress struct {
 	metav1.TypeMeta   `json:",inline"`
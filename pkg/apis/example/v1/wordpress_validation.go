@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// IsRWXStorageClass returns true if name is listed in rwxStorageClasses, the
+// comma separated storage classes supporting ReadWriteMany from the operator
+// configuration
+func IsRWXStorageClass(rwxStorageClasses string, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, class := range strings.Split(rwxStorageClasses, ",") {
+		if strings.TrimSpace(class) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WordpressReplicas returns the number of wordpress pods of spec.wordpress
+func (w *Wordpress) WordpressReplicas() int32 {
+	if w.Spec.Wordpress == nil || w.Spec.Wordpress.Replicas == nil {
+		return 1
+	}
+	return *w.Spec.Wordpress.Replicas
+}
+
+// Autoscaled returns true if the wordpress Deployment is scaled by a HorizontalPodAutoscaler
+func (w *Wordpress) Autoscaled() bool {
+	return w.Spec.Wordpress != nil && w.Spec.Wordpress.Autoscaling != nil
+}
+
+// MaxWordpressReplicas returns the maximum number of wordpress pods, from
+// spec.wordpress.replicas or the autoscaling limit
+func (w *Wordpress) MaxWordpressReplicas() int32 {
+	if w.Autoscaled() {
+		return w.Spec.Wordpress.Autoscaling.MaxReplicas
+	}
+	return w.WordpressReplicas()
+}
+
+// AllowsCloneTo returns true if spec.allowedCloneNamespaces lets namespace
+// clone or promote this instance
+func (w *Wordpress) AllowsCloneTo(namespace string) bool {
+	for _, ns := range w.Spec.AllowedCloneNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error if the spec is invalid, see ValidateReplicas
+func (w *Wordpress) Validate(rwxStorageClasses string) error {
+	if err := w.ValidateReplicas(rwxStorageClasses); err != nil {
+		return err
+	}
+	if err := w.ValidateTLS(); err != nil {
+		return err
+	}
+	if spec := w.Spec.Wordpress; spec != nil {
+		if err := spec.PodDisruptionBudget.Validate("spec.wordpress.podDisruptionBudget"); err != nil {
+			return err
+		}
+		if err := validateProbes("spec.wordpress", spec.LivenessProbe, spec.ReadinessProbe, spec.StartupProbe); err != nil {
+			return err
+		}
+		if err := ValidatePodTemplatePatch("spec.wordpress.podTemplatePatch", spec.PodTemplatePatch); err != nil {
+			return err
+		}
+	}
+	if spec := w.Spec.Mysql; spec != nil {
+		if err := spec.PodDisruptionBudget.Validate("spec.mysql.podDisruptionBudget"); err != nil {
+			return err
+		}
+		if err := validateProbes("spec.mysql", spec.LivenessProbe, spec.ReadinessProbe, spec.StartupProbe); err != nil {
+			return err
+		}
+		if err := ValidatePodTemplatePatch("spec.mysql.podTemplatePatch", spec.PodTemplatePatch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProbes returns an error if a liveness, readiness or startup probe
+// override doesn't have exactly one handler
+func validateProbes(field string, liveness, readiness, startup *corev1.Probe) error {
+	probes := map[string]*corev1.Probe{
+		"livenessProbe":  liveness,
+		"readinessProbe": readiness,
+		"startupProbe":   startup,
+	}
+	for _, name := range []string{"livenessProbe", "readinessProbe", "startupProbe"} {
+		probe := probes[name]
+		if probe == nil {
+			continue
+		}
+		handlers := 0
+		if probe.Exec != nil {
+			handlers++
+		}
+		if probe.HTTPGet != nil {
+			handlers++
+		}
+		if probe.TCPSocket != nil {
+			handlers++
+		}
+		if handlers != 1 {
+			return fmt.Errorf("%s.%s must set exactly one of exec, httpGet and tcpSocket", field, name)
+		}
+	}
+	return nil
+}
+
+// ValidateTLS returns an error if spec.tls is set without hostnames in
+// spec.ingress or spec.gateway to issue the certificate for
+func (w *Wordpress) ValidateTLS() error {
+	if w.Spec.TLS == nil {
+		return nil
+	}
+	if w.Spec.Ingress != nil && len(w.Spec.Ingress.Hosts) > 0 {
+		return nil
+	}
+	if w.Spec.Gateway != nil && len(w.Spec.Gateway.Hostnames) > 0 {
+		return nil
+	}
+	return fmt.Errorf("spec.tls requires hostnames in spec.ingress or spec.gateway")
+}
+
+// Validate returns an error if both minAvailable and maxUnavailable are set
+func (pdb *WordpressPodDisruptionBudgetSpec) Validate(field string) error {
+	if pdb != nil && pdb.MinAvailable != nil && pdb.MaxUnavailable != nil {
+		return fmt.Errorf("%s: only one of minAvailable and maxUnavailable may be set", field)
+	}
+	return nil
+}
+
+// ValidateReplicas returns an error if more than one wordpress pod would share
+// a ReadWriteOnce wordpress PVC, or the autoscaling limits are invalid
+func (w *Wordpress) ValidateReplicas(rwxStorageClasses string) error {
+	field := "spec.wordpress.replicas"
+	if w.Autoscaled() {
+		field = "spec.wordpress.autoscaling.maxReplicas"
+		autoscaling := w.Spec.Wordpress.Autoscaling
+		if autoscaling.MaxReplicas < 1 {
+			return fmt.Errorf("%s must be at least 1", field)
+		}
+		if autoscaling.MinReplicas != nil && (*autoscaling.MinReplicas < 1 || *autoscaling.MinReplicas > autoscaling.MaxReplicas) {
+			return fmt.Errorf("spec.wordpress.autoscaling.minReplicas must be between 1 and maxReplicas")
+		}
+	}
+
+	replicas := w.MaxWordpressReplicas()
+	if replicas < 0 {
+		return fmt.Errorf("%s must not be negative", field)
+	}
+	if replicas <= 1 {
+		return nil
+	}
+
+	spec := w.Spec.Wordpress
+	if spec.MediaOffload || IsRWXStorageClass(rwxStorageClasses, spec.StorageClassName) {
+		return nil
+	}
+	return fmt.Errorf("%s > 1 requires spec.wordpress.mediaOffload or a spec.wordpress.storageClassName supporting ReadWriteMany (%s)",
+		field, rwxStorageClasses)
+}
@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"testing"
+)
+
+func TestValidateTLS(t *testing.T) {
+	tls := &WordpressTLSSpec{IssuerRef: WordpressIssuerReference{Name: "letsencrypt"}}
+
+	tests := []struct {
+		name    string
+		spec    WordpressSpec
+		wantErr bool
+	}{
+		{name: "no tls", spec: WordpressSpec{}},
+		{name: "ingress hosts", spec: WordpressSpec{TLS: tls, Ingress: &WordpressIngressSpec{Hosts: []string{"blog.example.com"}}}},
+		{name: "gateway hostnames", spec: WordpressSpec{TLS: tls, Gateway: &WordpressGatewaySpec{Hostnames: []string{"blog.example.com"}}}},
+		{name: "no ingress or gateway", spec: WordpressSpec{TLS: tls}, wantErr: true},
+		{name: "ingress without hosts", spec: WordpressSpec{TLS: tls, Ingress: &WordpressIngressSpec{}}, wantErr: true},
+		{name: "gateway without hostnames", spec: WordpressSpec{TLS: tls, Gateway: &WordpressGatewaySpec{}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Wordpress{Spec: tt.spec}
+			if err := w.ValidateTLS(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := w.Validate(""); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReplicas(t *testing.T) {
+	replicas := func(n int32) *int32 { return &n }
+	rwx := "nfs, efs"
+
+	tests := []struct {
+		name    string
+		spec    *WordpressFrontendSpec
+		wantErr string
+	}{
+		{name: "no frontend spec"},
+		{name: "default replicas", spec: &WordpressFrontendSpec{}},
+		{name: "one replica", spec: &WordpressFrontendSpec{Replicas: replicas(1)}},
+		{name: "scaled to 0", spec: &WordpressFrontendSpec{Replicas: replicas(0)}},
+		{
+			name:    "negative replicas",
+			spec:    &WordpressFrontendSpec{Replicas: replicas(-1)},
+			wantErr: "spec.wordpress.replicas must not be negative",
+		},
+		{
+			name:    "replicas sharing a ReadWriteOnce PVC",
+			spec:    &WordpressFrontendSpec{Replicas: replicas(2)},
+			wantErr: "spec.wordpress.replicas > 1 requires spec.wordpress.mediaOffload or a spec.wordpress.storageClassName supporting ReadWriteMany (nfs, efs)",
+		},
+		{
+			name:    "replicas with a storage class not in the list",
+			spec:    &WordpressFrontendSpec{Replicas: replicas(2), StorageClassName: "standard"},
+			wantErr: "spec.wordpress.replicas > 1 requires spec.wordpress.mediaOffload or a spec.wordpress.storageClassName supporting ReadWriteMany (nfs, efs)",
+		},
+		{name: "replicas with a ReadWriteMany storage class", spec: &WordpressFrontendSpec{Replicas: replicas(3), StorageClassName: "efs"}},
+		{name: "replicas with media offload", spec: &WordpressFrontendSpec{Replicas: replicas(3), MediaOffload: true}},
+		{
+			name: "autoscaling up to one replica",
+			spec: &WordpressFrontendSpec{Replicas: replicas(5), Autoscaling: &WordpressAutoscalingSpec{MaxReplicas: 1}},
+		},
+		{
+			name:    "autoscaling sharing a ReadWriteOnce PVC",
+			spec:    &WordpressFrontendSpec{Autoscaling: &WordpressAutoscalingSpec{MaxReplicas: 3}},
+			wantErr: "spec.wordpress.autoscaling.maxReplicas > 1 requires spec.wordpress.mediaOffload or a spec.wordpress.storageClassName supporting ReadWriteMany (nfs, efs)",
+		},
+		{
+			name: "autoscaling with a ReadWriteMany storage class",
+			spec: &WordpressFrontendSpec{StorageClassName: "nfs", Autoscaling: &WordpressAutoscalingSpec{MinReplicas: replicas(2), MaxReplicas: 3}},
+		},
+		{
+			name:    "autoscaling without max replicas",
+			spec:    &WordpressFrontendSpec{Autoscaling: &WordpressAutoscalingSpec{}},
+			wantErr: "spec.wordpress.autoscaling.maxReplicas must be at least 1",
+		},
+		{
+			name:    "autoscaling with min replicas of 0",
+			spec:    &WordpressFrontendSpec{MediaOffload: true, Autoscaling: &WordpressAutoscalingSpec{MinReplicas: replicas(0), MaxReplicas: 3}},
+			wantErr: "spec.wordpress.autoscaling.minReplicas must be between 1 and maxReplicas",
+		},
+		{
+			name:    "autoscaling with min above max replicas",
+			spec:    &WordpressFrontendSpec{MediaOffload: true, Autoscaling: &WordpressAutoscalingSpec{MinReplicas: replicas(4), MaxReplicas: 3}},
+			wantErr: "spec.wordpress.autoscaling.minReplicas must be between 1 and maxReplicas",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Wordpress{Spec: WordpressSpec{Wordpress: tt.spec}}
+			err := w.ValidateReplicas(rwx)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateReplicas() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ValidateReplicas() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsRWXStorageClass(t *testing.T) {
+	tests := []struct {
+		classes string
+		name    string
+		want    bool
+	}{
+		{classes: "nfs,efs", name: "efs", want: true},
+		{classes: "nfs, efs", name: "efs", want: true},
+		{classes: "nfs", name: "standard"},
+		{classes: "nfs", name: ""},
+		{classes: "", name: ""},
+	}
+
+	for _, tt := range tests {
+		if got := IsRWXStorageClass(tt.classes, tt.name); got != tt.want {
+			t.Errorf("IsRWXStorageClass(%q, %q) = %v, want %v", tt.classes, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllowsCloneTo(t *testing.T) {
+	tests := []struct {
+		allowed   []string
+		namespace string
+		want      bool
+	}{
+		{allowed: []string{"staging", "preview"}, namespace: "preview", want: true},
+		{allowed: []string{"staging"}, namespace: "preview"},
+		{namespace: "staging"},
+	}
+
+	for _, tt := range tests {
+		w := &Wordpress{Spec: WordpressSpec{AllowedCloneNamespaces: tt.allowed}}
+		if got := w.AllowsCloneTo(tt.namespace); got != tt.want {
+			t.Errorf("AllowsCloneTo(%q) with %v = %v, want %v", tt.namespace, tt.allowed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WordpressPromotionScope is data promoted from the source to the target Wordpress instance
+type WordpressPromotionScope string
+
+const (
+	// WordpressPromotionDatabase: replace the target database, keeping the target site URL
+	WordpressPromotionDatabase WordpressPromotionScope = "database"
+	// WordpressPromotionUploads: replace wp-content/uploads
+	WordpressPromotionUploads WordpressPromotionScope = "uploads"
+	// WordpressPromotionThemes: replace wp-content/themes
+	WordpressPromotionThemes WordpressPromotionScope = "themes"
+	// WordpressPromotionPlugins: replace wp-content/plugins
+	WordpressPromotionPlugins WordpressPromotionScope = "plugins"
+)
+
+// WordpressPromotionPhase is the progress of a WordpressPromotion
+type WordpressPromotionPhase string
+
+const (
+	// WordpressPromotionBackingUp: a WordpressSnapshot of the target is being taken
+	WordpressPromotionBackingUp WordpressPromotionPhase = "BackingUp"
+	// WordpressPromotionSyncing: the Jobs copying, or comparing for a dry run, are running
+	WordpressPromotionSyncing WordpressPromotionPhase = "Syncing"
+	// WordpressPromotionCompleted: the promotion or dry run is complete, see Results
+	WordpressPromotionCompleted WordpressPromotionPhase = "Completed"
+	// WordpressPromotionFailed: the promotion failed, see Message
+	WordpressPromotionFailed WordpressPromotionPhase = "Failed"
+)
+
+// WordpressReference references a Wordpress instance in a namespace
+type WordpressReference struct {
+	// Name of the Wordpress instance
+	Name string `json:"name"`
+
+	// Namespace of the Wordpress instance
+	Namespace string `json:"namespace"`
+}
+
+// WordpressPromotionSpec defines the desired state of WordpressPromotion
+type WordpressPromotionSpec struct {
+	// Wordpress instance to promote from, in another namespace
+	Source WordpressReference `json:"source"`
+
+	// Name of the Wordpress instance to promote to, in the namespace of the WordpressPromotion
+	TargetName string `json:"targetName"`
+
+	// Data to promote: database, uploads, themes and/or plugins
+	Scope []WordpressPromotionScope `json:"scope"`
+
+	// Set to true to only report what would change in the target
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// WordpressPromotionResult reports what a promotion changed, or would change for a dry run
+type WordpressPromotionResult struct {
+	// database or files
+	Name string `json:"name"`
+
+	// Summary of the changes
+	Message string `json:"message"`
+}
+
+// WordpressPromotionStatus defines the observed state of WordpressPromotion
+type WordpressPromotionStatus struct {
+	// Phase of the promotion
+	Phase WordpressPromotionPhase `json:"phase,omitempty"`
+
+	// Human readable details about the phase
+	Message string `json:"message,omitempty"`
+
+	// Name of the WordpressSnapshot of the target taken before promoting
+	SafetySnapshot string `json:"safetySnapshot,omitempty"`
+
+	// What was changed, or would be changed for a dry run
+	Results []WordpressPromotionResult `json:"results,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WordpressPromotion is the Schema for the wordpresspromotions API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=wordpresspromotions,scope=Namespaced
+// +kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.source.namespace"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.targetName"
+// +kubebuilder:printcolumn:name="Dry Run",type="boolean",JSONPath=".spec.dryRun"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type WordpressPromotion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WordpressPromotionSpec   `json:"spec,omitempty"`
+	Status WordpressPromotionStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WordpressPromotionList contains a list of WordpressPromotion
+type WordpressPromotionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WordpressPromotion `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WordpressPromotion{}, &WordpressPromotionList{})
+}
@@ -0,0 +1,93 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WordpressSnapshotPhase is the progress of a WordpressSnapshot
+type WordpressSnapshotPhase string
+
+const (
+	// WordpressSnapshotPending: waiting for another snapshot of the same Wordpress to complete
+	WordpressSnapshotPending WordpressSnapshotPhase = "Pending"
+	// WordpressSnapshotQuiescing: mysql and wordpress are being scaled down before the snapshot
+	WordpressSnapshotQuiescing WordpressSnapshotPhase = "Quiescing"
+	// WordpressSnapshotSnapshotting: VolumeSnapshots are created and not yet ready to use
+	WordpressSnapshotSnapshotting WordpressSnapshotPhase = "Snapshotting"
+	// WordpressSnapshotReady: both VolumeSnapshots are ready to use
+	WordpressSnapshotReady WordpressSnapshotPhase = "Ready"
+	// WordpressSnapshotFailed: the snapshot failed, see Message. Failed is final,
+	// create a new WordpressSnapshot to retry
+	WordpressSnapshotFailed WordpressSnapshotPhase = "Failed"
+)
+
+// WordpressSnapshotSpec defines the desired state of WordpressSnapshot
+type WordpressSnapshotSpec struct {
+	// Name of the Wordpress instance in the same namespace to snapshot
+	WordpressName string `json:"wordpressName"`
+
+	// VolumeSnapshotClass used for the VolumeSnapshots, defaults to the cluster default class
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+
+	// Time for the VolumeSnapshots to be ready to use once quiescing started, after which the snapshot fails,
+	// defaults to 30m
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// WordpressSnapshotStatus defines the observed state of WordpressSnapshot
+type WordpressSnapshotStatus struct {
+	// Phase of the snapshot
+	Phase WordpressSnapshotPhase `json:"phase,omitempty"`
+
+	// Human readable details about the phase
+	Message string `json:"message,omitempty"`
+
+	// Name of the VolumeSnapshot of the mysql PVC
+	MysqlVolumeSnapshot string `json:"mysqlVolumeSnapshot,omitempty"`
+
+	// Name of the VolumeSnapshot of the wordpress PVC
+	WordpressVolumeSnapshot string `json:"wordpressVolumeSnapshot,omitempty"`
+
+	// Time both volumes were snapshotted while mysql and wordpress were quiesced
+	SnapshotTime *metav1.Time `json:"snapshotTime,omitempty"`
+
+	// Replicas of the wordpress Deployment before it was quiesced, restored
+	// once the volumes are snapshotted
+	WordpressReplicas *int32 `json:"wordpressReplicas,omitempty"`
+
+	// Time quiescing started, spec.timeout counts from it
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Set while this snapshot holds mysql and wordpress scaled down, they are
+	// scaled back up once the volumes are snapshotted or the snapshot fails
+	Quiesced bool `json:"quiesced,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WordpressSnapshot is the Schema for the wordpresssnapshots API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=wordpresssnapshots,scope=Namespaced
+// +kubebuilder:printcolumn:name="Wordpress",type="string",JSONPath=".spec.wordpressName"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type WordpressSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WordpressSnapshotSpec   `json:"spec,omitempty"`
+	Status WordpressSnapshotStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WordpressSnapshotList contains a list of WordpressSnapshot
+type WordpressSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WordpressSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WordpressSnapshot{}, &WordpressSnapshotList{})
+}
@@ -6,7 +6,11 @@ package v1
 
 import (
 	status "github.com/operator-framework/operator-sdk/pkg/status"
+	v2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -14,7 +18,7 @@ func (in *Wordpress) DeepCopyInto(out *Wordpress) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 	return
 }
@@ -37,6 +41,272 @@ func (in *Wordpress) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressAutoscalingSpec) DeepCopyInto(out *WordpressAutoscalingSpec) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetCPUUtilizationPercentage != nil {
+		in, out := &in.TargetCPUUtilizationPercentage, &out.TargetCPUUtilizationPercentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetMemoryUtilizationPercentage != nil {
+		in, out := &in.TargetMemoryUtilizationPercentage, &out.TargetMemoryUtilizationPercentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]v2.MetricSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressAutoscalingSpec.
+func (in *WordpressAutoscalingSpec) DeepCopy() *WordpressAutoscalingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressAutoscalingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressCloneSource) DeepCopyInto(out *WordpressCloneSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressCloneSource.
+func (in *WordpressCloneSource) DeepCopy() *WordpressCloneSource {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressCloneSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressCloneStatus) DeepCopyInto(out *WordpressCloneStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressCloneStatus.
+func (in *WordpressCloneStatus) DeepCopy() *WordpressCloneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressCloneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressFrontendSpec) DeepCopyInto(out *WordpressFrontendSpec) {
+	*out = *in
+	in.WordpressSchedulingSpec.DeepCopyInto(&out.WordpressSchedulingSpec)
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(WordpressAutoscalingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodDisruptionBudget != nil {
+		in, out := &in.PodDisruptionBudget, &out.PodDisruptionBudget
+		*out = new(WordpressPodDisruptionBudgetSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StartupProbe != nil {
+		in, out := &in.StartupProbe, &out.StartupProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplatePatch != nil {
+		in, out := &in.PodTemplatePatch, &out.PodTemplatePatch
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressFrontendSpec.
+func (in *WordpressFrontendSpec) DeepCopy() *WordpressFrontendSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressFrontendSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressGatewayReference) DeepCopyInto(out *WordpressGatewayReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressGatewayReference.
+func (in *WordpressGatewayReference) DeepCopy() *WordpressGatewayReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressGatewayReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressGatewaySpec) DeepCopyInto(out *WordpressGatewaySpec) {
+	*out = *in
+	out.GatewayRef = in.GatewayRef
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]WordpressHeaderMatch, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressGatewaySpec.
+func (in *WordpressGatewaySpec) DeepCopy() *WordpressGatewaySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressGatewaySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressHeaderMatch) DeepCopyInto(out *WordpressHeaderMatch) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressHeaderMatch.
+func (in *WordpressHeaderMatch) DeepCopy() *WordpressHeaderMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressHeaderMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressImagesStatus) DeepCopyInto(out *WordpressImagesStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressImagesStatus.
+func (in *WordpressImagesStatus) DeepCopy() *WordpressImagesStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressImagesStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressIngressSpec) DeepCopyInto(out *WordpressIngressSpec) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressIngressSpec.
+func (in *WordpressIngressSpec) DeepCopy() *WordpressIngressSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressIngressSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressIssuerReference) DeepCopyInto(out *WordpressIssuerReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressIssuerReference.
+func (in *WordpressIssuerReference) DeepCopy() *WordpressIssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressIssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WordpressList) DeepCopyInto(out *WordpressList) {
 	*out = *in
@@ -70,9 +340,557 @@ func (in *WordpressList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressMysqlSpec) DeepCopyInto(out *WordpressMysqlSpec) {
+	*out = *in
+	in.WordpressSchedulingSpec.DeepCopyInto(&out.WordpressSchedulingSpec)
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodDisruptionBudget != nil {
+		in, out := &in.PodDisruptionBudget, &out.PodDisruptionBudget
+		*out = new(WordpressPodDisruptionBudgetSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StartupProbe != nil {
+		in, out := &in.StartupProbe, &out.StartupProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplatePatch != nil {
+		in, out := &in.PodTemplatePatch, &out.PodTemplatePatch
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressMysqlSpec.
+func (in *WordpressMysqlSpec) DeepCopy() *WordpressMysqlSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressMysqlSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressNetworkPolicySpec) DeepCopyInto(out *WordpressNetworkPolicySpec) {
+	*out = *in
+	if in.IngressNamespaces != nil {
+		in, out := &in.IngressNamespaces, &out.IngressNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EgressCIDRs != nil {
+		in, out := &in.EgressCIDRs, &out.EgressCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressNetworkPolicySpec.
+func (in *WordpressNetworkPolicySpec) DeepCopy() *WordpressNetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressNetworkPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPodDisruptionBudgetSpec) DeepCopyInto(out *WordpressPodDisruptionBudgetSpec) {
+	*out = *in
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPodDisruptionBudgetSpec.
+func (in *WordpressPodDisruptionBudgetSpec) DeepCopy() *WordpressPodDisruptionBudgetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPodDisruptionBudgetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPodSecurityStatus) DeepCopyInto(out *WordpressPodSecurityStatus) {
+	*out = *in
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPodSecurityStatus.
+func (in *WordpressPodSecurityStatus) DeepCopy() *WordpressPodSecurityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPodSecurityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPreset) DeepCopyInto(out *WordpressPreset) {
+	*out = *in
+	in.Mysql.DeepCopyInto(&out.Mysql)
+	in.Wordpress.DeepCopyInto(&out.Wordpress)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPreset.
+func (in *WordpressPreset) DeepCopy() *WordpressPreset {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPreset)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPromotion) DeepCopyInto(out *WordpressPromotion) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPromotion.
+func (in *WordpressPromotion) DeepCopy() *WordpressPromotion {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPromotion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WordpressPromotion) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPromotionList) DeepCopyInto(out *WordpressPromotionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]WordpressPromotion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPromotionList.
+func (in *WordpressPromotionList) DeepCopy() *WordpressPromotionList {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPromotionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WordpressPromotionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPromotionResult) DeepCopyInto(out *WordpressPromotionResult) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPromotionResult.
+func (in *WordpressPromotionResult) DeepCopy() *WordpressPromotionResult {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPromotionResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPromotionSpec) DeepCopyInto(out *WordpressPromotionSpec) {
+	*out = *in
+	out.Source = in.Source
+	if in.Scope != nil {
+		in, out := &in.Scope, &out.Scope
+		*out = make([]WordpressPromotionScope, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPromotionSpec.
+func (in *WordpressPromotionSpec) DeepCopy() *WordpressPromotionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPromotionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressPromotionStatus) DeepCopyInto(out *WordpressPromotionStatus) {
+	*out = *in
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = make([]WordpressPromotionResult, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressPromotionStatus.
+func (in *WordpressPromotionStatus) DeepCopy() *WordpressPromotionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressPromotionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressReference) DeepCopyInto(out *WordpressReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressReference.
+func (in *WordpressReference) DeepCopy() *WordpressReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressSchedulingSpec) DeepCopyInto(out *WordpressSchedulingSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]corev1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressSchedulingSpec.
+func (in *WordpressSchedulingSpec) DeepCopy() *WordpressSchedulingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressSchedulingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressServiceAccountSpec) DeepCopyInto(out *WordpressServiceAccountSpec) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressServiceAccountSpec.
+func (in *WordpressServiceAccountSpec) DeepCopy() *WordpressServiceAccountSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressServiceAccountSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressServiceSpec) DeepCopyInto(out *WordpressServiceSpec) {
+	*out = *in
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressServiceSpec.
+func (in *WordpressServiceSpec) DeepCopy() *WordpressServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressSnapshot) DeepCopyInto(out *WordpressSnapshot) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressSnapshot.
+func (in *WordpressSnapshot) DeepCopy() *WordpressSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WordpressSnapshot) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressSnapshotList) DeepCopyInto(out *WordpressSnapshotList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]WordpressSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressSnapshotList.
+func (in *WordpressSnapshotList) DeepCopy() *WordpressSnapshotList {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressSnapshotList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WordpressSnapshotList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressSnapshotSpec) DeepCopyInto(out *WordpressSnapshotSpec) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressSnapshotSpec.
+func (in *WordpressSnapshotSpec) DeepCopy() *WordpressSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressSnapshotStatus) DeepCopyInto(out *WordpressSnapshotStatus) {
+	*out = *in
+	if in.SnapshotTime != nil {
+		in, out := &in.SnapshotTime, &out.SnapshotTime
+		*out = (*in).DeepCopy()
+	}
+	if in.WordpressReplicas != nil {
+		in, out := &in.WordpressReplicas, &out.WordpressReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressSnapshotStatus.
+func (in *WordpressSnapshotStatus) DeepCopy() *WordpressSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WordpressSpec) DeepCopyInto(out *WordpressSpec) {
 	*out = *in
+	if in.DeletionBackupTimeout != nil {
+		in, out := &in.DeletionBackupTimeout, &out.DeletionBackupTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.CloneFrom != nil {
+		in, out := &in.CloneFrom, &out.CloneFrom
+		*out = new(WordpressCloneSource)
+		**out = **in
+	}
+	if in.AllowedCloneNamespaces != nil {
+		in, out := &in.AllowedCloneNamespaces, &out.AllowedCloneNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(WordpressServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(WordpressIngressSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = new(WordpressGatewaySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(WordpressTLSSpec)
+		**out = **in
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(WordpressNetworkPolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceAccount != nil {
+		in, out := &in.ServiceAccount, &out.ServiceAccount
+		*out = new(WordpressServiceAccountSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Wordpress != nil {
+		in, out := &in.Wordpress, &out.Wordpress
+		*out = new(WordpressFrontendSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Mysql != nil {
+		in, out := &in.Mysql, &out.Mysql
+		*out = new(WordpressMysqlSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -96,6 +914,31 @@ func (in *WordpressStatus) DeepCopyInto(out *WordpressStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Clone != nil {
+		in, out := &in.Clone, &out.Clone
+		*out = new(WordpressCloneStatus)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(WordpressTLSStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PodSecurity != nil {
+		in, out := &in.PodSecurity, &out.PodSecurity
+		*out = new(WordpressPodSecurityStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = new(WordpressImagesStatus)
+		**out = **in
+	}
 	return
 }
 
@@ -108,3 +951,44 @@ func (in *WordpressStatus) DeepCopy() *WordpressStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressTLSSpec) DeepCopyInto(out *WordpressTLSSpec) {
+	*out = *in
+	out.IssuerRef = in.IssuerRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressTLSSpec.
+func (in *WordpressTLSSpec) DeepCopy() *WordpressTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WordpressTLSStatus) DeepCopyInto(out *WordpressTLSStatus) {
+	*out = *in
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+	if in.RenewalTime != nil {
+		in, out := &in.RenewalTime, &out.RenewalTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WordpressTLSStatus.
+func (in *WordpressTLSStatus) DeepCopy() *WordpressTLSStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WordpressTLSStatus)
+	in.DeepCopyInto(out)
+	return out
+}
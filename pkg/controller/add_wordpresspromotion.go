@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"github.com/srust/wordpress-operator/pkg/controller/wordpresspromotion"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, wordpresspromotion.Add)
+}
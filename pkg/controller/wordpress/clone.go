@@ -0,0 +1,473 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Cloning copies the database and wp-content of spec.cloneFrom into the new
+// instance before its wordpress Deployment is created. Resource names are
+// fixed per namespace, so the source always lives in another namespace:
+//   - wordpress-clone-database: Job that dumps the source database over the
+//     network into the new mysql and rewrites the site URL
+//   - wordpress-clone-content: Job that receives a tar stream of wp-content
+//     into the new wordpress PVC, exposed by the wordpress-clone Service
+//   - wordpress-clone-to-<namespace>: Job in the source namespace that
+//     mounts the source wordpress PVC and sends it to wordpress-clone
+const (
+	cloneSecretName      = "wordpress-clone-source"
+	cloneDatabaseJobName = "wordpress-clone-database"
+	cloneContentJobName  = "wordpress-clone-content"
+	cloneServiceName     = "wordpress-clone"
+	clonePort            = 8080
+)
+
+// copy the source database and replace the source site URL, rows holding
+// serialized PHP data are left alone as their lengths would no longer match
+const cloneDatabaseScript = `set -eo pipefail
+until mysqladmin ping -h wordpress-mysql --silent; do sleep 2; done
+MYSQL_PWD="$SOURCE_PASSWORD" mysqldump -h "$SOURCE_HOST" -u root --single-transaction --databases wordpress \
+  | MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysql -h wordpress-mysql -u root
+MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysql -h wordpress-mysql -u root wordpress <<EOF
+SET @old = (SELECT option_value FROM wp_options WHERE option_name = 'siteurl');
+UPDATE wp_posts SET guid = REPLACE(guid, @old, '$SITE_URL'), post_content = REPLACE(post_content, @old, '$SITE_URL');
+UPDATE wp_options SET option_value = REPLACE(option_value, @old, '$SITE_URL') WHERE option_name IN ('siteurl', 'home');
+EOF
+`
+
+// receive wp-content from the sender Job
+const cloneReceiveScript = `nc -l -p 8080 | tar xf - -C /var/www/html`
+
+// send wp-content, retrying until the receiver is listening
+const cloneSendScript = `until tar cf - -C /var/www/html . | nc -w 30 "$TARGET_HOST" 8080; do sleep 5; done`
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile Clone
+/////////////////////////////////////////////////////////////////////
+
+// clone spec.cloneFrom into w, returns true once the wordpress Deployment may be created
+func (r *ReconcileWordpress) reconcileClone(w *examplev1.Wordpress) (bool, error) {
+	if w.Spec.CloneFrom == nil {
+		return true, nil
+	}
+
+	if w.Status.Clone != nil {
+		switch w.Status.Clone.Phase {
+		case examplev1.WordpressCloneCompleted:
+			return true, nil
+		case examplev1.WordpressCloneFailed:
+			return false, nil
+		}
+	}
+
+	if err := validateCloneSource(w); err != nil {
+		return false, r.updateCloneStatus(w, examplev1.WordpressCloneStatus{
+			Phase:   examplev1.WordpressCloneFailed,
+			Message: err.Error(),
+		})
+	}
+
+	source := &examplev1.Wordpress{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Spec.CloneFrom.Namespace, Name: w.Spec.CloneFrom.Name}, source)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, r.updateCloneStatus(w, examplev1.WordpressCloneStatus{
+				Phase:   examplev1.WordpressCloneFailed,
+				Message: fmt.Sprintf("source Wordpress %s/%s not found", w.Spec.CloneFrom.Namespace, w.Spec.CloneFrom.Name),
+			})
+		}
+		return false, err
+	}
+	if !source.AllowsCloneTo(w.Namespace) {
+		return false, r.updateCloneStatus(w, examplev1.WordpressCloneStatus{
+			Phase: examplev1.WordpressCloneFailed,
+			Message: fmt.Sprintf("source Wordpress %s/%s does not allow cloning to namespace %s, add it to its spec.allowedCloneNamespaces",
+				source.Namespace, source.Name, w.Namespace),
+		})
+	}
+
+	if err := r.CreateObject(r.genCloneSecret(w, source), "Secret"); err != nil {
+		return false, err
+	}
+	if err := r.CreateObject(r.genCloneDatabaseJob(w), "Job"); err != nil {
+		return false, err
+	}
+	if err := r.CreateObject(r.genCloneService(w), "Service"); err != nil {
+		return false, err
+	}
+	if err := r.CreateObject(r.genCloneContentJob(w), "Job"); err != nil {
+		return false, err
+	}
+	if err := r.CreateObject(r.genCloneSenderJob(w), "Job"); err != nil {
+		return false, err
+	}
+
+	databaseCopied, databaseFailed, err := r.findJobResult(w.Namespace, cloneDatabaseJobName)
+	if err != nil {
+		return false, err
+	}
+	contentCopied, contentFailed, err := r.findJobResult(w.Namespace, cloneContentJobName)
+	if err != nil {
+		return false, err
+	}
+
+	clone := examplev1.WordpressCloneStatus{
+		Phase:          examplev1.WordpressCloneCloning,
+		DatabaseCopied: databaseCopied,
+		ContentCopied:  contentCopied,
+		Message:        fmt.Sprintf("cloning from %s/%s", w.Spec.CloneFrom.Namespace, w.Spec.CloneFrom.Name),
+	}
+
+	switch {
+	case databaseFailed:
+		clone.Phase = examplev1.WordpressCloneFailed
+		clone.Message = fmt.Sprintf("Job %s failed", cloneDatabaseJobName)
+	case contentFailed:
+		clone.Phase = examplev1.WordpressCloneFailed
+		clone.Message = fmt.Sprintf("Job %s failed", cloneContentJobName)
+	case databaseCopied && contentCopied:
+		clone.Phase = examplev1.WordpressCloneCompleted
+		clone.Message = fmt.Sprintf("cloned from %s/%s", w.Spec.CloneFrom.Namespace, w.Spec.CloneFrom.Name)
+	}
+
+	// Completed and Failed are final, a failed clone is not retried
+	if clone.Phase != examplev1.WordpressCloneCloning {
+		if err := r.finalizeClone(w); err != nil {
+			return false, err
+		}
+	}
+
+	err = r.updateCloneStatus(w, clone)
+	return clone.Phase == examplev1.WordpressCloneCompleted, err
+}
+
+// check spec.cloneFrom can be cloned into w
+func validateCloneSource(w *examplev1.Wordpress) error {
+	source := w.Spec.CloneFrom
+
+	if source.Namespace == "" || source.Namespace == w.Namespace {
+		return fmt.Errorf("cloneFrom.namespace must be another namespace, only one Wordpress instance is supported per namespace")
+	}
+
+	u, err := url.Parse(source.SiteURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("cloneFrom.siteURL %q must be an absolute http or https URL", source.SiteURL)
+	}
+	if strings.ContainsAny(source.SiteURL, `'\`) {
+		return fmt.Errorf("cloneFrom.siteURL %q must not contain quotes or backslashes", source.SiteURL)
+	}
+
+	return nil
+}
+
+// return whether a Job owned by w succeeded or failed
+func (r *ReconcileWordpress) findJobResult(namespace string, name string) (bool, bool, error) {
+	job := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, job)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, false, nil
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return false, true, nil
+		}
+	}
+	return false, false, nil
+}
+
+// set the clone progress in the Wordpress status
+func (r *ReconcileWordpress) updateCloneStatus(w *examplev1.Wordpress, clone examplev1.WordpressCloneStatus) error {
+	if w.Status.Clone != nil && *w.Status.Clone == clone {
+		return nil
+	}
+	w.Status.Clone = &clone
+
+	err := r.client.Status().Update(context.TODO(), w)
+	if err != nil {
+		r.logger.Error(err, "Failed to update wordpress Status")
+	}
+	return err
+}
+
+// returns true if the objects used while copying may still exist, they are
+// removed once the clone completed or failed
+func cloneInProgress(w *examplev1.Wordpress) bool {
+	if w.Spec.CloneFrom == nil || w.Spec.CloneFrom.Namespace == w.Namespace {
+		return false
+	}
+	return w.Status.Clone == nil || w.Status.Clone.Phase == examplev1.WordpressCloneCloning
+}
+
+// remove the source password and the objects only needed while copying
+func (r *ReconcileWordpress) finalizeClone(w *examplev1.Wordpress) error {
+	if err := r.DeleteObject(r.genCloneSecret(w, nil), "Secret"); err != nil {
+		return err
+	}
+	if err := r.DeleteObject(r.genCloneService(w), "Service"); err != nil {
+		return err
+	}
+
+	// not owned by w, as owner references cannot cross namespaces. Without
+	// access to the source namespace the Job could not have been created.
+	job := r.genCloneSenderJob(w)
+	err := r.client.Delete(context.TODO(), job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if errors.IsForbidden(err) {
+		r.logger.Info("not allowed to delete object", "Kind", "Job", "Namespace", job.Namespace, "Name", job.Name)
+		return nil
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		r.logger.Error(err, "failed to delete object", "Kind", "Job", "Namespace", job.Namespace, "Name", job.Name)
+		return err
+	}
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// Clone objects
+/////////////////////////////////////////////////////////////////////
+
+// return the secret holding the root password of the source mysql
+func (r *ReconcileWordpress) genCloneSecret(w *examplev1.Wordpress, source *examplev1.Wordpress) *corev1.Secret {
+	secretKey := os.Getenv("WORDPRESS_SECRET_KEY")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cloneSecretName,
+			Namespace: w.Namespace,
+		},
+		Type: "Opaque",
+	}
+	if source != nil {
+		secret.StringData = map[string]string{
+			secretKey: source.Spec.SqlRootPassword,
+		}
+	}
+
+	controllerutil.SetControllerReference(w, secret, r.scheme)
+	return secret
+}
+
+// return the Job copying the source database into the new mysql
+func (r *ReconcileWordpress) genCloneDatabaseJob(w *examplev1.Wordpress) *batchv1.Job {
+	secretKey := os.Getenv("WORDPRESS_SECRET_KEY")
+	imageName := MysqlImage(w)
+	backoffLimit := int32(3)
+	automount := false
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cloneDatabaseJobName,
+			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "wordpress", "tier": "clone"},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automount,
+					ImagePullSecrets:             imagePullSecrets(w),
+					Containers: []corev1.Container{{
+						Image:   imageName,
+						Name:    "clone-database",
+						Command: []string{"bash", "-c", cloneDatabaseScript},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "SOURCE_HOST",
+								Value: fmt.Sprintf("wordpress-mysql.%s.svc", w.Spec.CloneFrom.Namespace),
+							},
+							{
+								Name: "SOURCE_PASSWORD",
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: cloneSecretName},
+										Key:                  secretKey,
+									},
+								},
+							},
+							{
+								Name:      "MYSQL_ROOT_PASSWORD",
+								ValueFrom: r.genRootPasswordSecret(),
+							},
+							{
+								Name:  "SITE_URL",
+								Value: w.Spec.CloneFrom.SiteURL,
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	controllerutil.SetControllerReference(w, job, r.scheme)
+	return job
+}
+
+// return the Service the sender Job streams wp-content to
+func (r *ReconcileWordpress) genCloneService(w *examplev1.Wordpress) *corev1.Service {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cloneServiceName,
+			Namespace: w.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				"app":      "wordpress",
+				"tier":     "clone",
+				"job-name": cloneContentJobName,
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Port:       clonePort,
+					TargetPort: intstr.FromInt(clonePort),
+					Name:       "clone",
+				},
+			},
+		},
+	}
+
+	controllerutil.SetControllerReference(w, service, r.scheme)
+	return service
+}
+
+// return the Job receiving wp-content into the new wordpress PVC
+func (r *ReconcileWordpress) genCloneContentJob(w *examplev1.Wordpress) *batchv1.Job {
+	imageName := os.Getenv("WORDPRESS_IMAGE_BUSYBOX")
+	backoffLimit := int32(3)
+	automount := false
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cloneContentJobName,
+			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "wordpress", "tier": "clone"},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automount,
+					ImagePullSecrets:             imagePullSecrets(w),
+					Containers: []corev1.Container{{
+						Image:   imageName,
+						Name:    "clone-content",
+						Command: []string{"sh", "-c", cloneReceiveScript},
+						Ports: []corev1.ContainerPort{{
+							ContainerPort: clonePort,
+							Name:          "clone",
+						}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "wordpress-persistent-storage",
+							MountPath: "/var/www/html",
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "wordpress-persistent-storage",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: "wp-pv-claim",
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	controllerutil.SetControllerReference(w, job, r.scheme)
+	return job
+}
+
+// return the Job in the source namespace sending wp-content to the clone.
+// It runs next to the source wordpress pod, as the PVC is ReadWriteOnce.
+func (r *ReconcileWordpress) genCloneSenderJob(w *examplev1.Wordpress) *batchv1.Job {
+	imageName := os.Getenv("WORDPRESS_IMAGE_BUSYBOX")
+	backoffLimit := int32(3)
+	automount := false
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("wordpress-clone-to-%s", w.Namespace),
+			Namespace: w.Spec.CloneFrom.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "wordpress", "tier": "clone"},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automount,
+					// the secrets of the site may not exist in the source namespace
+					ImagePullSecrets: examplev1.ImagePullSecrets(os.Getenv("WORDPRESS_IMAGE_PULL_SECRETS"), nil),
+					Affinity: &corev1.Affinity{
+						PodAffinity: &corev1.PodAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+								LabelSelector: &metav1.LabelSelector{
+									MatchLabels: map[string]string{"app": "wordpress", "tier": "frontend"},
+								},
+								TopologyKey: "kubernetes.io/hostname",
+							}},
+						},
+					},
+					Containers: []corev1.Container{{
+						Image:   imageName,
+						Name:    "clone-content",
+						Command: []string{"sh", "-c", cloneSendScript},
+						Env: []corev1.EnvVar{{
+							Name:  "TARGET_HOST",
+							Value: fmt.Sprintf("%s.%s.svc", cloneServiceName, w.Namespace),
+						}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "wordpress-persistent-storage",
+							MountPath: "/var/www/html",
+							ReadOnly:  true,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "wordpress-persistent-storage",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: "wp-pv-claim",
+								ReadOnly:  true,
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	return job
+}
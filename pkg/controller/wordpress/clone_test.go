@@ -0,0 +1,160 @@
+package wordpress
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCloneInProgress(t *testing.T) {
+	source := &examplev1.WordpressCloneSource{Name: "mysite", Namespace: "production", SiteURL: "http://staging.example.com"}
+
+	tests := []struct {
+		name      string
+		cloneFrom *examplev1.WordpressCloneSource
+		status    *examplev1.WordpressCloneStatus
+		want      bool
+	}{
+		{name: "not a clone"},
+		{name: "same namespace", cloneFrom: &examplev1.WordpressCloneSource{Name: "mysite", Namespace: "staging"}},
+		{name: "not started", cloneFrom: source, want: true},
+		{name: "cloning", cloneFrom: source, status: &examplev1.WordpressCloneStatus{Phase: examplev1.WordpressCloneCloning}, want: true},
+		{name: "completed", cloneFrom: source, status: &examplev1.WordpressCloneStatus{Phase: examplev1.WordpressCloneCompleted}},
+		{name: "failed", cloneFrom: source, status: &examplev1.WordpressCloneStatus{Phase: examplev1.WordpressCloneFailed}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "staging"},
+				Spec:       examplev1.WordpressSpec{CloneFrom: tt.cloneFrom},
+				Status:     examplev1.WordpressStatus{Clone: tt.status},
+			}
+			if got := cloneInProgress(w); got != tt.want {
+				t.Errorf("cloneInProgress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCloneSourceOptIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   []string
+		wantPhase examplev1.WordpressClonePhase
+		wantJobs  bool
+	}{
+		{name: "not allowed", wantPhase: examplev1.WordpressCloneFailed},
+		{name: "other namespaces allowed", allowed: []string{"preview"}, wantPhase: examplev1.WordpressCloneFailed},
+		{name: "allowed", allowed: []string{"preview", "staging"}, wantPhase: examplev1.WordpressCloneCloning, wantJobs: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconcileWordpress(t)
+			if err := clientgoscheme.AddToScheme(r.scheme); err != nil {
+				t.Fatal(err)
+			}
+			r.logger = log
+			source := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+				Spec:       examplev1.WordpressSpec{AllowedCloneNamespaces: tt.allowed},
+			}
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "staging"},
+				Spec: examplev1.WordpressSpec{
+					CloneFrom: &examplev1.WordpressCloneSource{Name: "mysite", Namespace: "production", SiteURL: "http://staging.example.com"},
+				},
+			}
+			r.client = fake.NewClientBuilder().WithScheme(r.scheme).WithObjects(source, w).Build()
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "staging", Name: "mysite"}, w); err != nil {
+				t.Fatal(err)
+			}
+
+			done, err := r.reconcileClone(w)
+			if err != nil || done {
+				t.Fatalf("reconcileClone() = %v, %v, want not done", done, err)
+			}
+			if w.Status.Clone == nil || w.Status.Clone.Phase != tt.wantPhase {
+				t.Fatalf("clone status = %+v, want %s", w.Status.Clone, tt.wantPhase)
+			}
+			if tt.wantPhase == examplev1.WordpressCloneFailed && !strings.Contains(w.Status.Clone.Message, "allowedCloneNamespaces") {
+				t.Errorf("message = %q, want the opt-in field", w.Status.Clone.Message)
+			}
+
+			for _, key := range []types.NamespacedName{
+				{Namespace: "staging", Name: cloneSecretName},
+				{Namespace: "staging", Name: cloneDatabaseJobName},
+				{Namespace: "production", Name: "wordpress-clone-to-staging"},
+			} {
+				var obj client.Object = &batchv1.Job{}
+				if key.Name == cloneSecretName {
+					obj = &corev1.Secret{}
+				}
+				err := r.client.Get(context.TODO(), key, obj)
+				if found := err == nil; found != tt.wantJobs {
+					t.Errorf("%s found = %v, want %v", key, found, tt.wantJobs)
+				}
+			}
+		})
+	}
+}
+
+func TestGenCloneDatabaseJob(t *testing.T) {
+	t.Setenv("WORDPRESS_IMAGE_MYSQL", "mysql:5.6")
+	t.Setenv("WORDPRESS_IMAGE_PULL_SECRETS", "operator-registry")
+	pinned := &examplev1.WordpressImagesStatus{Mysql: "mysql:5.6@sha256:abc"}
+	secrets := []corev1.LocalObjectReference{{Name: "site-registry"}}
+
+	tests := []struct {
+		name        string
+		pin         bool
+		wantImage   string
+		wantSecrets []corev1.LocalObjectReference
+	}{
+		{
+			name:        "tag",
+			wantImage:   "mysql:5.6",
+			wantSecrets: []corev1.LocalObjectReference{{Name: "operator-registry"}, {Name: "site-registry"}},
+		},
+		{
+			name:        "pinned digest",
+			pin:         true,
+			wantImage:   "mysql:5.6@sha256:abc",
+			wantSecrets: []corev1.LocalObjectReference{{Name: "operator-registry"}, {Name: "site-registry"}},
+		},
+	}
+
+	r := testReconcileWordpress(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "staging"},
+				Spec: examplev1.WordpressSpec{
+					CloneFrom:        &examplev1.WordpressCloneSource{Name: "mysite", Namespace: "production", SiteURL: "http://staging.example.com"},
+					PinImageDigests:  tt.pin,
+					ImagePullSecrets: secrets,
+				},
+				Status: examplev1.WordpressStatus{Images: pinned},
+			}
+			pod := r.genCloneDatabaseJob(w).Spec.Template.Spec
+			if image := pod.Containers[0].Image; image != tt.wantImage {
+				t.Errorf("image = %q, want %q", image, tt.wantImage)
+			}
+			if !reflect.DeepEqual(pod.ImagePullSecrets, tt.wantSecrets) {
+				t.Errorf("imagePullSecrets = %v, want %v", pod.ImagePullSecrets, tt.wantSecrets)
+			}
+		})
+	}
+}
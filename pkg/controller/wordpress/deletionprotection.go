@@ -0,0 +1,63 @@
+package wordpress
+
+import (
+	"context"
+	"os"
+
+	condv1 "github.com/operator-framework/operator-sdk/pkg/status"
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// condition reporting whether spec.deletionProtection is enforced
+const deletionProtectedCondition condv1.ConditionType = "DeletionProtected"
+
+/////////////////////////////////////////////////////////////////////
+// Deletion Protection
+/////////////////////////////////////////////////////////////////////
+
+// returns whether the operator serves the admission webhooks enforcing
+// spec.deletionProtection
+func webhooksEnabled() bool {
+	return os.Getenv("WORDPRESS_ENABLE_WEBHOOKS") == "true"
+}
+
+// returns the DeletionProtected condition of an instance asking for
+// deletion protection, False when the webhooks are disabled
+func genDeletionProtectedCondition(enabled bool) condv1.Condition {
+	cond := condv1.Condition{
+		Type:               deletionProtectedCondition,
+		Status:             corev1.ConditionTrue,
+		Reason:             condv1.ConditionReason("WebhookEnabled"),
+		Message:            "the admission webhook rejects deletion of the instance and its PVCs",
+		LastTransitionTime: metav1.Now(),
+	}
+	if !enabled {
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = condv1.ConditionReason("WebhooksDisabled")
+		cond.Message = "deletionProtection is not enforced, the operator runs without WORDPRESS_ENABLE_WEBHOOKS"
+	}
+	return cond
+}
+
+// sets the DeletionProtected condition when spec.deletionProtection is set,
+// so that an unenforced protection doesn't go unnoticed
+func (r *ReconcileWordpress) updateDeletionProtectedStatus(w *examplev1.Wordpress) error {
+	changed := false
+	if w.Spec.DeletionProtection {
+		changed = w.Status.Conditions.SetCondition(genDeletionProtectedCondition(webhooksEnabled()))
+	} else {
+		changed = w.Status.Conditions.RemoveCondition(deletionProtectedCondition)
+	}
+	if !changed {
+		return nil
+	}
+
+	err := r.client.Status().Update(context.TODO(), w)
+	if err != nil {
+		r.logger.Error(err, "Failed to update wordpress Deletion Protection Status")
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package wordpress
+
+import (
+	"context"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpdateDeletionProtectedStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		protection bool
+		webhooks   string
+		wantStatus corev1.ConditionStatus
+		wantReason string
+	}{
+		{name: "not protected", webhooks: "false"},
+		{name: "not protected with webhooks", webhooks: "true"},
+		{name: "webhooks enabled", protection: true, webhooks: "true", wantStatus: corev1.ConditionTrue, wantReason: "WebhookEnabled"},
+		{name: "webhooks disabled", protection: true, webhooks: "false", wantStatus: corev1.ConditionFalse, wantReason: "WebhooksDisabled"},
+		{name: "webhooks unset", protection: true, wantStatus: corev1.ConditionFalse, wantReason: "WebhooksDisabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WORDPRESS_ENABLE_WEBHOOKS", tt.webhooks)
+			r := testReconcileWordpress(t)
+			r.logger = log
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+				Spec:       examplev1.WordpressSpec{DeletionProtection: tt.protection},
+			}
+			// a condition left from an earlier setting is replaced or removed
+			w.Status.Conditions.SetCondition(genDeletionProtectedCondition(tt.webhooks != "true"))
+			r.client = fake.NewClientBuilder().WithScheme(r.scheme).WithObjects(w).Build()
+			key := types.NamespacedName{Namespace: "production", Name: "mysite"}
+			if err := r.client.Get(context.TODO(), key, w); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.updateDeletionProtectedStatus(w); err != nil {
+				t.Fatalf("updateDeletionProtectedStatus() error = %v", err)
+			}
+			stored := &examplev1.Wordpress{}
+			if err := r.client.Get(context.TODO(), key, stored); err != nil {
+				t.Fatal(err)
+			}
+			cond := stored.Status.Conditions.GetCondition(deletionProtectedCondition)
+			if tt.wantReason == "" {
+				if cond != nil {
+					t.Errorf("condition = %+v, want none", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != tt.wantStatus || string(cond.Reason) != tt.wantReason {
+				t.Errorf("condition = %+v, want %s %s", cond, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
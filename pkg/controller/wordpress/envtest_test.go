@@ -0,0 +1,70 @@
+package wordpress
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// start an API server with the operator CRDs and the optional CRDs in
+// testdata, and return a reconciler using it. The test is skipped if the
+// envtest binaries are not installed.
+func startTestEnv(t *testing.T) (*ReconcileWordpress, func()) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		if _, err := os.Stat("/usr/local/kubebuilder/bin/kube-apiserver"); err != nil {
+			t.Skip("envtest binaries not found, set KUBEBUILDER_ASSETS to run this test")
+		}
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths: []string{filepath.Join("..", "..", "..", "deploy", "crds"), "testdata"},
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := examplev1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		env.Stop()
+		t.Fatal(err)
+	}
+	r := &ReconcileWordpress{client: c, apiReader: c, scheme: scheme, logger: log}
+	return r, func() { env.Stop() }
+}
+
+// create a namespace and a Wordpress instance in it
+func createTestWordpress(t *testing.T, r *ReconcileWordpress, namespace string, spec examplev1.WordpressSpec) *examplev1.Wordpress {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if err := r.client.Create(context.TODO(), ns); err != nil {
+		t.Fatal(err)
+	}
+
+	spec.SqlRootPassword = "password"
+	w := &examplev1.Wordpress{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: namespace},
+		Spec:       spec,
+	}
+	if err := r.client.Create(context.TODO(), w); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
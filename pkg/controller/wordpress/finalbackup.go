@@ -0,0 +1,128 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	condv1 "github.com/operator-framework/operator-sdk/pkg/status"
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// condition reporting the final WordpressSnapshot of deletionPolicy Backup
+	finalBackupCondition condv1.ConditionType = "FinalBackupCompleted"
+
+	// time the finalizer waits for the final WordpressSnapshot by default
+	defaultDeletionBackupTimeout = 30 * time.Minute
+
+	// interval to poll the final WordpressSnapshot
+	finalBackupPollInterval = 10 * time.Second
+)
+
+/////////////////////////////////////////////////////////////////////
+// Final Backup
+/////////////////////////////////////////////////////////////////////
+
+// returns the name of the final WordpressSnapshot, unique per deletion so
+// that an instance recreated with the same name takes a new one
+func finalBackupName(w *examplev1.Wordpress) string {
+	return fmt.Sprintf("%s-final-%d", w.Name, w.GetDeletionTimestamp().Unix())
+}
+
+// returns the time the finalizer waits for the final WordpressSnapshot
+func deletionBackupTimeout(w *examplev1.Wordpress) time.Duration {
+	if w.Spec.DeletionBackupTimeout != nil {
+		return w.Spec.DeletionBackupTimeout.Duration
+	}
+	return defaultDeletionBackupTimeout
+}
+
+// returns the final WordpressSnapshot, not owned by w so that it outlives
+// the instance and can populate a new one with spec.fromSnapshot
+func genFinalBackup(w *examplev1.Wordpress) *examplev1.WordpressSnapshot {
+	return &examplev1.WordpressSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      finalBackupName(w),
+			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: examplev1.WordpressSnapshotSpec{
+			WordpressName: w.Name,
+		},
+	}
+}
+
+// returns the FinalBackupCompleted condition
+func genFinalBackupCondition(status corev1.ConditionStatus, reason string, message string) condv1.Condition {
+	return condv1.Condition{
+		Type:               finalBackupCondition,
+		Status:             status,
+		Reason:             condv1.ConditionReason(reason),
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+}
+
+// returns the condition of the final WordpressSnapshot s at now, and
+// whether the PVCs may be deleted
+func finalBackupStatus(w *examplev1.Wordpress, s *examplev1.WordpressSnapshot, now time.Time) (condv1.Condition, bool) {
+	retained := "the PVCs are retained, delete the WordpressSnapshot to retry or set deletionPolicy to Delete"
+	timeout := deletionBackupTimeout(w)
+
+	switch {
+	case s.Status.Phase == examplev1.WordpressSnapshotReady:
+		message := fmt.Sprintf("WordpressSnapshot %s is Ready", s.Name)
+		return genFinalBackupCondition(corev1.ConditionTrue, "Completed", message), true
+	case s.Status.Phase == examplev1.WordpressSnapshotFailed:
+		message := fmt.Sprintf("WordpressSnapshot %s failed: %s; %s", s.Name, s.Status.Message, retained)
+		return genFinalBackupCondition(corev1.ConditionFalse, "Failed", message), false
+	case !s.CreationTimestamp.IsZero() && now.After(s.CreationTimestamp.Add(timeout)):
+		message := fmt.Sprintf("WordpressSnapshot %s is not Ready after %s; %s", s.Name, timeout, retained)
+		return genFinalBackupCondition(corev1.ConditionFalse, "TimedOut", message), false
+	}
+	message := fmt.Sprintf("waiting for WordpressSnapshot %s", s.Name)
+	return genFinalBackupCondition(corev1.ConditionFalse, "InProgress", message), false
+}
+
+// takes the final WordpressSnapshot of deletionPolicy Backup and returns
+// whether it is Ready, or not needed, and the PVCs may be deleted
+func (r *ReconcileWordpress) finalBackup(w *examplev1.Wordpress) (bool, error) {
+	if w.Spec.DeletionPolicy != examplev1.WordpressDeletionPolicyBackup {
+		return true, nil
+	}
+
+	// nothing to back up if the instance never got its mysql PVC
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: "mysql-pv-claim"}, pvc)
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	snapshot := &examplev1.WordpressSnapshot{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: finalBackupName(w)}, snapshot)
+	if errors.IsNotFound(err) {
+		snapshot = genFinalBackup(w)
+		err = r.CreateObject(snapshot, "WordpressSnapshot")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cond, done := finalBackupStatus(w, snapshot, time.Now())
+	if w.Status.Conditions.SetCondition(cond) {
+		err = r.client.Status().Update(context.TODO(), w)
+		if err != nil {
+			r.logger.Error(err, "Failed to update wordpress Final Backup Status")
+			return false, err
+		}
+	}
+	return done, nil
+}
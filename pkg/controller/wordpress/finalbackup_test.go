@@ -0,0 +1,180 @@
+package wordpress
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFinalBackupStatus(t *testing.T) {
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := func(phase examplev1.WordpressSnapshotPhase) *examplev1.WordpressSnapshot {
+		return &examplev1.WordpressSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysite-final-1", CreationTimestamp: metav1.NewTime(created)},
+			Status:     examplev1.WordpressSnapshotStatus{Phase: phase, Message: "VolumeSnapshot mysite-final-1-mysql failed"},
+		}
+	}
+	w := &examplev1.Wordpress{}
+	timeout := &examplev1.Wordpress{
+		Spec: examplev1.WordpressSpec{DeletionBackupTimeout: &metav1.Duration{Duration: time.Hour}},
+	}
+
+	tests := []struct {
+		name       string
+		w          *examplev1.Wordpress
+		snapshot   *examplev1.WordpressSnapshot
+		now        time.Time
+		wantStatus corev1.ConditionStatus
+		wantReason string
+		wantDone   bool
+	}{
+		{
+			name:       "in progress",
+			w:          w,
+			snapshot:   snapshot(examplev1.WordpressSnapshotQuiescing),
+			now:        created.Add(time.Minute),
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "InProgress",
+		},
+		{
+			name:       "just created",
+			w:          w,
+			snapshot:   &examplev1.WordpressSnapshot{ObjectMeta: metav1.ObjectMeta{Name: "mysite-final-1"}},
+			now:        created,
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "InProgress",
+		},
+		{
+			name:       "ready",
+			w:          w,
+			snapshot:   snapshot(examplev1.WordpressSnapshotReady),
+			now:        created.Add(time.Hour),
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "Completed",
+			wantDone:   true,
+		},
+		{
+			name:       "failed",
+			w:          w,
+			snapshot:   snapshot(examplev1.WordpressSnapshotFailed),
+			now:        created.Add(time.Minute),
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "Failed",
+		},
+		{
+			name:       "default timeout",
+			w:          w,
+			snapshot:   snapshot(examplev1.WordpressSnapshotSnapshotting),
+			now:        created.Add(31 * time.Minute),
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "TimedOut",
+		},
+		{
+			name:       "timeout from the spec",
+			w:          timeout,
+			snapshot:   snapshot(examplev1.WordpressSnapshotSnapshotting),
+			now:        created.Add(31 * time.Minute),
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "InProgress",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, done := finalBackupStatus(tt.w, tt.snapshot, tt.now)
+			if cond.Type != finalBackupCondition || cond.Status != tt.wantStatus || string(cond.Reason) != tt.wantReason {
+				t.Errorf("condition = %s %s %s, want %s %s", cond.Type, cond.Status, cond.Reason, tt.wantStatus, tt.wantReason)
+			}
+			if !strings.Contains(cond.Message, "mysite-final-1") {
+				t.Errorf("message = %q, want the snapshot name", cond.Message)
+			}
+			if done != tt.wantDone {
+				t.Errorf("done = %v, want %v", done, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestFinalBackup(t *testing.T) {
+	deleted := metav1.NewTime(time.Unix(1591012800, 0))
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "mysql-pv-claim", Namespace: "production"}}
+
+	tests := []struct {
+		name         string
+		policy       examplev1.WordpressDeletionPolicy
+		objects      []client.Object
+		wantDone     bool
+		wantSnapshot bool
+	}{
+		{name: "no policy", objects: []client.Object{pvc}, wantDone: true},
+		{name: "delete", policy: examplev1.WordpressDeletionPolicyDelete, objects: []client.Object{pvc}, wantDone: true},
+		{name: "backup without volumes", policy: examplev1.WordpressDeletionPolicyBackup, wantDone: true},
+		{name: "backup", policy: examplev1.WordpressDeletionPolicyBackup, objects: []client.Object{pvc}, wantSnapshot: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconcileWordpress(t)
+			if err := clientgoscheme.AddToScheme(r.scheme); err != nil {
+				t.Fatal(err)
+			}
+			r.logger = log
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "mysite",
+					Namespace:         "production",
+					DeletionTimestamp: &deleted,
+					Finalizers:        []string{wordpressFinalizer},
+				},
+				Spec: examplev1.WordpressSpec{DeletionPolicy: tt.policy},
+			}
+			r.client = fake.NewClientBuilder().WithScheme(r.scheme).WithObjects(append(tt.objects, w)...).Build()
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "production", Name: "mysite"}, w); err != nil {
+				t.Fatal(err)
+			}
+
+			done, err := r.finalBackup(w)
+			if err != nil {
+				t.Fatalf("finalBackup() error = %v", err)
+			}
+			if done != tt.wantDone {
+				t.Errorf("done = %v, want %v", done, tt.wantDone)
+			}
+
+			snapshot := &examplev1.WordpressSnapshot{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: "production", Name: "mysite-final-1591012800"}, snapshot)
+			if found := err == nil; found != tt.wantSnapshot {
+				t.Fatalf("snapshot found = %v, want %v", found, tt.wantSnapshot)
+			}
+			if !tt.wantSnapshot {
+				return
+			}
+			if snapshot.Spec.WordpressName != "mysite" || len(snapshot.OwnerReferences) != 0 {
+				t.Errorf("snapshot = %+v, want an unowned snapshot of mysite", snapshot)
+			}
+			cond := w.Status.Conditions.GetCondition(finalBackupCondition)
+			if cond == nil || cond.Reason != "InProgress" {
+				t.Errorf("condition = %+v, want InProgress", cond)
+			}
+
+			// the PVCs are deleted once the snapshot is Ready
+			snapshot.Status.Phase = examplev1.WordpressSnapshotReady
+			if err := r.client.Status().Update(context.TODO(), snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if done, err := r.finalBackup(w); err != nil || !done {
+				t.Errorf("finalBackup() = %v, %v, want done once Ready", done, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package wordpress
+
+import (
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// HTTPRoutes are handled as unstructured objects, so the operator does not
+// depend on the Gateway API client and still starts on clusters without the
+// Gateway API CRDs installed.
+var httpRouteGVK = schema.GroupVersionKind{
+	Group:   "gateway.networking.k8s.io",
+	Version: "v1",
+	Kind:    "HTTPRoute",
+}
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile Wordpress HTTPRoute
+/////////////////////////////////////////////////////////////////////
+
+// returns the wordpress HTTPRoute object, defaults applied by the API server
+// are set explicitly
+func (r *ReconcileWordpress) genWordpressHTTPRoute(w *examplev1.Wordpress) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetName("wordpress")
+	route.SetNamespace(w.Namespace)
+	route.SetLabels(map[string]string{"app": "wordpress"})
+
+	spec := w.Spec.Gateway
+	if spec == nil {
+		return route
+	}
+
+	parentRef := map[string]interface{}{
+		"group":     httpRouteGVK.Group,
+		"kind":      "Gateway",
+		"name":      spec.GatewayRef.Name,
+		"namespace": gatewayNamespace(w),
+	}
+	if spec.GatewayRef.SectionName != "" {
+		parentRef["sectionName"] = spec.GatewayRef.SectionName
+	}
+
+	headers := []interface{}{}
+	for _, header := range spec.Headers {
+		headers = append(headers, map[string]interface{}{
+			"type":  "Exact",
+			"name":  header.Name,
+			"value": header.Value,
+		})
+	}
+
+	paths := spec.Paths
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	matches := []interface{}{}
+	for _, path := range paths {
+		match := map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":  "PathPrefix",
+				"value": path,
+			},
+		}
+		if len(headers) > 0 {
+			match["headers"] = headers
+		}
+		matches = append(matches, match)
+	}
+
+	routeSpec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"matches": matches,
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"group":  "",
+						"kind":   "Service",
+						"name":   "wordpress",
+						"port":   int64(wordpressServicePort(w)),
+						"weight": int64(1),
+					},
+				},
+			},
+		},
+	}
+	if len(spec.Hostnames) > 0 {
+		hostnames := []interface{}{}
+		for _, hostname := range spec.Hostnames {
+			hostnames = append(hostnames, hostname)
+		}
+		routeSpec["hostnames"] = hostnames
+	}
+	route.Object["spec"] = routeSpec
+
+	return route
+}
+
+// returns the namespace of the Gateway of spec.gateway
+func gatewayNamespace(w *examplev1.Wordpress) string {
+	if w.Spec.Gateway.GatewayRef.Namespace != "" {
+		return w.Spec.Gateway.GatewayRef.Namespace
+	}
+	return w.Namespace
+}
+
+// Creates, Updates or Deletes the Wordpress HTTPRoute object
+func (r *ReconcileWordpress) reconcileWordpressHTTPRoute(w *examplev1.Wordpress) error {
+	route := r.genWordpressHTTPRoute(w)
+	return r.reconcileUnstructured(w, route, w.Spec.Gateway != nil, "HTTPRoute")
+}
@@ -0,0 +1,150 @@
+package wordpress
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGenWordpressHTTPRoute(t *testing.T) {
+	backend := func(port int64) []interface{} {
+		return []interface{}{
+			map[string]interface{}{"group": "", "kind": "Service", "name": "wordpress", "port": port, "weight": int64(1)},
+		}
+	}
+	pathMatch := func(path string) map[string]interface{} {
+		return map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": path}}
+	}
+
+	tests := []struct {
+		name string
+		spec examplev1.WordpressSpec
+		want interface{}
+	}{
+		{name: "no gateway"},
+		{
+			name: "defaults",
+			spec: examplev1.WordpressSpec{
+				Gateway: &examplev1.WordpressGatewaySpec{GatewayRef: examplev1.WordpressGatewayReference{Name: "public"}},
+			},
+			want: map[string]interface{}{
+				"parentRefs": []interface{}{
+					map[string]interface{}{"group": "gateway.networking.k8s.io", "kind": "Gateway", "name": "public", "namespace": "default"},
+				},
+				"rules": []interface{}{
+					map[string]interface{}{"matches": []interface{}{pathMatch("/")}, "backendRefs": backend(80)},
+				},
+			},
+		},
+		{
+			name: "hostnames, paths, headers, listener and port",
+			spec: examplev1.WordpressSpec{
+				Service: &examplev1.WordpressServiceSpec{Port: 8080},
+				Gateway: &examplev1.WordpressGatewaySpec{
+					GatewayRef: examplev1.WordpressGatewayReference{Name: "public", Namespace: "gateways", SectionName: "https"},
+					Hostnames:  []string{"blog.example.com", "www.example.com"},
+					Paths:      []string{"/blog", "/wp-admin"},
+					Headers:    []examplev1.WordpressHeaderMatch{{Name: "X-Canary", Value: "true"}},
+				},
+			},
+			want: map[string]interface{}{
+				"parentRefs": []interface{}{
+					map[string]interface{}{
+						"group":       "gateway.networking.k8s.io",
+						"kind":        "Gateway",
+						"name":        "public",
+						"namespace":   "gateways",
+						"sectionName": "https",
+					},
+				},
+				"hostnames": []interface{}{"blog.example.com", "www.example.com"},
+				"rules": []interface{}{
+					map[string]interface{}{
+						"matches": []interface{}{
+							map[string]interface{}{
+								"path": map[string]interface{}{"type": "PathPrefix", "value": "/blog"},
+								"headers": []interface{}{
+									map[string]interface{}{"type": "Exact", "name": "X-Canary", "value": "true"},
+								},
+							},
+							map[string]interface{}{
+								"path": map[string]interface{}{"type": "PathPrefix", "value": "/wp-admin"},
+								"headers": []interface{}{
+									map[string]interface{}{"type": "Exact", "name": "X-Canary", "value": "true"},
+								},
+							},
+						},
+						"backendRefs": backend(8080),
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "default"},
+				Spec:       tt.spec,
+			}
+			route := (&ReconcileWordpress{}).genWordpressHTTPRoute(w)
+			if route.GroupVersionKind() != httpRouteGVK || route.GetName() != "wordpress" || route.GetNamespace() != "default" {
+				t.Errorf("route = %s %s/%s, want %s default/wordpress", route.GroupVersionKind(), route.GetNamespace(), route.GetName(), httpRouteGVK)
+			}
+			if !reflect.DeepEqual(route.Object["spec"], tt.want) {
+				t.Errorf("spec = %v, want %v", route.Object["spec"], tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileWordpressHTTPRoute(t *testing.T) {
+	r := testReconcileWordpress(t)
+	r.logger = log
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(httpRouteGVK, meta.RESTScopeNamespace)
+	w := &examplev1.Wordpress{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "default", UID: "1"},
+		Spec: examplev1.WordpressSpec{
+			Gateway: &examplev1.WordpressGatewaySpec{GatewayRef: examplev1.WordpressGatewayReference{Name: "public"}},
+		},
+	}
+	r.client = fake.NewClientBuilder().WithScheme(r.scheme).WithRESTMapper(mapper).Build()
+
+	get := func() bool {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "wordpress"}, route)
+		return err == nil
+	}
+
+	if err := r.reconcileWordpressHTTPRoute(w); err != nil {
+		t.Fatalf("reconcileWordpressHTTPRoute() error = %v", err)
+	}
+	if !get() {
+		t.Fatalf("HTTPRoute not created")
+	}
+
+	// removing spec.gateway deletes the HTTPRoute
+	w.Spec.Gateway = nil
+	if err := r.reconcileWordpressHTTPRoute(w); err != nil {
+		t.Fatalf("reconcileWordpressHTTPRoute() error = %v", err)
+	}
+	if get() {
+		t.Errorf("HTTPRoute not deleted once spec.gateway is removed")
+	}
+
+	// the Gateway API CRDs are only needed while spec.gateway is set
+	r.client = fake.NewClientBuilder().WithScheme(r.scheme).WithRESTMapper(meta.NewDefaultRESTMapper(nil)).Build()
+	if err := r.reconcileWordpressHTTPRoute(w); err != nil {
+		t.Errorf("reconcileWordpressHTTPRoute() without the CRDs error = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package wordpress
+
+import (
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The HorizontalPodAutoscaler is handled as an unstructured object of an
+// optional API version, autoscaling/v2 is served since Kubernetes 1.23
+var hpaGVK = schema.GroupVersionKind{
+	Group:   "autoscaling",
+	Version: "v2",
+	Kind:    "HorizontalPodAutoscaler",
+}
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile Wordpress HorizontalPodAutoscaler
+/////////////////////////////////////////////////////////////////////
+
+// returns a Resource metric with a target average utilization
+func resourceUtilizationMetric(name corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
+
+// returns the wordpress HorizontalPodAutoscaler object
+func (r *ReconcileWordpress) genWordpressHPA(w *examplev1.Wordpress) (*unstructured.Unstructured, error) {
+	hpa := &unstructured.Unstructured{}
+	hpa.SetGroupVersionKind(hpaGVK)
+	hpa.SetName("wordpress")
+	hpa.SetNamespace(w.Namespace)
+	hpa.SetLabels(map[string]string{"app": "wordpress"})
+
+	if !w.Autoscaled() {
+		return hpa, nil
+	}
+	autoscaling := w.Spec.Wordpress.Autoscaling
+
+	metrics := []autoscalingv2.MetricSpec{}
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, resourceUtilizationMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilizationPercentage))
+	}
+	if autoscaling.TargetMemoryUtilizationPercentage != nil {
+		metrics = append(metrics, resourceUtilizationMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilizationPercentage))
+	}
+	metrics = append(metrics, autoscaling.Metrics...)
+
+	spec := autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "wordpress",
+		},
+		MinReplicas: autoscaling.MinReplicas,
+		MaxReplicas: autoscaling.MaxReplicas,
+		Metrics:     metrics,
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&spec)
+	if err != nil {
+		return nil, err
+	}
+	hpa.Object["spec"] = object
+
+	return hpa, nil
+}
+
+// Creates, Updates or Deletes the Wordpress HorizontalPodAutoscaler object
+func (r *ReconcileWordpress) reconcileWordpressHPA(w *examplev1.Wordpress) error {
+	hpa, err := r.genWordpressHPA(w)
+	if err != nil {
+		return err
+	}
+	return r.reconcileUnstructured(w, hpa, w.Autoscaled(), "HorizontalPodAutoscaler")
+}
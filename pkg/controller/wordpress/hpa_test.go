@@ -0,0 +1,101 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGenWordpressHPA(t *testing.T) {
+	int32Ptr := func(n int32) *int32 { return &n }
+	requests := autoscalingv2.MetricSpec{
+		Type: autoscalingv2.PodsMetricSourceType,
+		Pods: &autoscalingv2.PodsMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: "http_requests"},
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		wordpress   *examplev1.WordpressFrontendSpec
+		wantSpec    bool
+		wantMin     *int32
+		wantMax     int32
+		wantMetrics []autoscalingv2.MetricSpec
+	}{
+		{name: "not autoscaled"},
+		{name: "replicas without autoscaling", wordpress: &examplev1.WordpressFrontendSpec{Replicas: int32Ptr(3)}},
+		{
+			name:      "defaults",
+			wordpress: &examplev1.WordpressFrontendSpec{Autoscaling: &examplev1.WordpressAutoscalingSpec{MaxReplicas: 3}},
+			wantSpec:  true,
+			wantMax:   3,
+		},
+		{
+			name: "utilization targets and metrics",
+			wordpress: &examplev1.WordpressFrontendSpec{Autoscaling: &examplev1.WordpressAutoscalingSpec{
+				MinReplicas:                       int32Ptr(2),
+				MaxReplicas:                       5,
+				TargetCPUUtilizationPercentage:    int32Ptr(60),
+				TargetMemoryUtilizationPercentage: int32Ptr(80),
+				Metrics:                           []autoscalingv2.MetricSpec{requests},
+			}},
+			wantSpec: true,
+			wantMin:  int32Ptr(2),
+			wantMax:  5,
+			wantMetrics: []autoscalingv2.MetricSpec{
+				resourceUtilizationMetric(corev1.ResourceCPU, 60),
+				resourceUtilizationMetric(corev1.ResourceMemory, 80),
+				requests,
+			},
+		},
+	}
+
+	r := testReconcileWordpress(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+				Spec:       examplev1.WordpressSpec{Wordpress: tt.wordpress},
+			}
+			hpa, err := r.genWordpressHPA(w)
+			if err != nil {
+				t.Fatalf("genWordpressHPA() error = %v", err)
+			}
+			if hpa.GroupVersionKind() != hpaGVK || hpa.GetName() != "wordpress" || hpa.GetNamespace() != "production" {
+				t.Errorf("hpa = %s %s/%s, want %s production/wordpress", hpa.GroupVersionKind(), hpa.GetNamespace(), hpa.GetName(), hpaGVK)
+			}
+
+			object, ok := hpa.Object["spec"].(map[string]interface{})
+			if ok != tt.wantSpec {
+				t.Fatalf("spec set = %v, want %v", ok, tt.wantSpec)
+			}
+			if !tt.wantSpec {
+				return
+			}
+			spec := autoscalingv2.HorizontalPodAutoscalerSpec{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, &spec); err != nil {
+				t.Fatal(err)
+			}
+			wantTarget := autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "wordpress"}
+			if spec.ScaleTargetRef != wantTarget {
+				t.Errorf("scaleTargetRef = %+v, want %+v", spec.ScaleTargetRef, wantTarget)
+			}
+			if !reflect.DeepEqual(spec.MinReplicas, tt.wantMin) || spec.MaxReplicas != tt.wantMax {
+				t.Errorf("replicas = %v..%d, want %v..%d", spec.MinReplicas, spec.MaxReplicas, tt.wantMin, tt.wantMax)
+			}
+			if len(spec.Metrics) != 0 || len(tt.wantMetrics) != 0 {
+				if !reflect.DeepEqual(spec.Metrics, tt.wantMetrics) {
+					t.Errorf("metrics = %+v, want %+v", spec.Metrics, tt.wantMetrics)
+				}
+			}
+		})
+	}
+}
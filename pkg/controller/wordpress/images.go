@@ -0,0 +1,141 @@
+package wordpress
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	condv1 "github.com/operator-framework/operator-sdk/pkg/status"
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// condition reporting whether the images are pinned with spec.pinImageDigests
+const imageDigestsCondition condv1.ConditionType = "ImageDigestsPinned"
+
+/////////////////////////////////////////////////////////////////////
+// Images
+/////////////////////////////////////////////////////////////////////
+
+// returns the secrets for pulling the images of the pods of w
+func imagePullSecrets(w *examplev1.Wordpress) []corev1.LocalObjectReference {
+	return w.ImagePullSecrets(os.Getenv("WORDPRESS_IMAGE_PULL_SECRETS"))
+}
+
+// returns image without its digest
+func imageWithoutDigest(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	return image
+}
+
+// returns the pinned image if it was resolved from image, or image
+func pinnedImage(image string, pinned string) string {
+	if pinned != "" && imageWithoutDigest(pinned) == imageWithoutDigest(image) {
+		return pinned
+	}
+	return image
+}
+
+// MysqlImage returns the image of the mysql container, pinned to its digest
+// with spec.pinImageDigests, also used by the Jobs copying the database
+func MysqlImage(w *examplev1.Wordpress) string {
+	image := os.Getenv("WORDPRESS_IMAGE_MYSQL")
+	if w.Spec.PinImageDigests && w.Status.Images != nil {
+		return pinnedImage(image, w.Status.Images.Mysql)
+	}
+	return image
+}
+
+// returns the image of the wordpress container
+func wordpressImage(w *examplev1.Wordpress) string {
+	image := os.Getenv("WORDPRESS_IMAGE_WORDPRESS")
+	if w.Spec.PinImageDigests && w.Status.Images != nil {
+		return pinnedImage(image, w.Status.Images.Wordpress)
+	}
+	return image
+}
+
+// returns image pinned to a digest, keeping an image pinned before
+func (r *ReconcileWordpress) pinImage(w *examplev1.Wordpress, image string, pinned string) (string, error) {
+	if strings.Contains(image, "@") {
+		return image, nil
+	}
+	if pinned != "" && imageWithoutDigest(pinned) == image {
+		return pinned, nil
+	}
+	digest, err := r.resolveImageDigest(w, image)
+	if err != nil {
+		return "", err
+	}
+	return image + "@" + digest, nil
+}
+
+// returns the ImageDigestsPinned condition, False with the errors of the
+// images that couldn't be resolved
+func genImageDigestsCondition(errs []string) condv1.Condition {
+	cond := condv1.Condition{
+		Type:               imageDigestsCondition,
+		Status:             corev1.ConditionTrue,
+		Reason:             condv1.ConditionReason("DigestsResolved"),
+		Message:            "the images are pinned to their digests",
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(errs) > 0 {
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = condv1.ConditionReason("ResolveFailed")
+		cond.Message = "using the tags, " + strings.Join(errs, ", ")
+	}
+	return cond
+}
+
+// resolves the mysql and wordpress images to digests with
+// spec.pinImageDigests, once per configured image, and records them in the
+// status the Deployments are generated from. An image whose digest can't be
+// resolved is used by its tag and resolved again on the next reconcile
+func (r *ReconcileWordpress) reconcileImageDigests(w *examplev1.Wordpress) error {
+	var images *examplev1.WordpressImagesStatus
+	changed := false
+	if w.Spec.PinImageDigests {
+		previous := w.Status.Images
+		if previous == nil {
+			previous = &examplev1.WordpressImagesStatus{}
+		}
+
+		errs := []string{}
+		images = &examplev1.WordpressImagesStatus{}
+		for _, image := range []struct {
+			configured string
+			previous   string
+			pinned     *string
+		}{
+			{os.Getenv("WORDPRESS_IMAGE_MYSQL"), previous.Mysql, &images.Mysql},
+			{os.Getenv("WORDPRESS_IMAGE_WORDPRESS"), previous.Wordpress, &images.Wordpress},
+		} {
+			pinned, err := r.pinImage(w, image.configured, image.previous)
+			if err != nil {
+				r.logger.Error(err, "Failed to resolve the image digest, using the tag", "Image", image.configured)
+				errs = append(errs, err.Error())
+				continue
+			}
+			*image.pinned = pinned
+		}
+		changed = w.Status.Conditions.SetCondition(genImageDigestsCondition(errs))
+	} else {
+		changed = w.Status.Conditions.RemoveCondition(imageDigestsCondition)
+	}
+
+	if equality.Semantic.DeepEqual(w.Status.Images, images) && !changed {
+		return nil
+	}
+	w.Status.Images = images
+	err := r.client.Status().Update(context.TODO(), w)
+	if err != nil {
+		r.logger.Error(err, "Failed to update wordpress Images Status")
+	}
+	return err
+}
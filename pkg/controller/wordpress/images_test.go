@@ -0,0 +1,83 @@
+package wordpress
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileImageDigests(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/mysql/manifests/5.6" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:abc")
+	}))
+	defer server.Close()
+	client := registryClient
+	registryClient = server.Client()
+	defer func() { registryClient = client }()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	t.Setenv("WORDPRESS_IMAGE_PULL_SECRETS", "")
+	t.Setenv("WORDPRESS_IMAGE_MYSQL", host+"/mysql:5.6")
+	t.Setenv("WORDPRESS_IMAGE_WORDPRESS", host+"/wordpress:missing")
+
+	r := testReconcileWordpress(t)
+	r.logger = log
+	w := &examplev1.Wordpress{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+		Spec:       examplev1.WordpressSpec{PinImageDigests: true},
+	}
+	r.client = fake.NewClientBuilder().WithScheme(r.scheme).WithObjects(w).Build()
+	key := types.NamespacedName{Namespace: "production", Name: "mysite"}
+	if err := r.client.Get(context.TODO(), key, w); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.reconcileImageDigests(w); err != nil {
+		t.Fatalf("reconcileImageDigests() error = %v", err)
+	}
+	if mysql := MysqlImage(w); mysql != host+"/mysql:5.6@sha256:abc" {
+		t.Errorf("mysql image = %q, want it pinned", mysql)
+	}
+	if wordpress := wordpressImage(w); wordpress != host+"/wordpress:missing" {
+		t.Errorf("wordpress image = %q, want the tag", wordpress)
+	}
+	cond := w.Status.Conditions.GetCondition(imageDigestsCondition)
+	if cond == nil || cond.Status != corev1.ConditionFalse || !strings.Contains(cond.Message, "wordpress:missing") {
+		t.Errorf("condition = %+v, want False for the wordpress image", cond)
+	}
+
+	stored := &examplev1.Wordpress{}
+	if err := r.client.Get(context.TODO(), key, stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status.Images == nil || stored.Status.Images.Mysql != host+"/mysql:5.6@sha256:abc" || stored.Status.Images.Wordpress != "" {
+		t.Errorf("stored images = %+v, want only mysql pinned", stored.Status.Images)
+	}
+
+	// the digest is resolved on the next reconcile once the image exists
+	t.Setenv("WORDPRESS_IMAGE_WORDPRESS", host+"/mysql:5.6")
+	w = stored
+	if err := r.reconcileImageDigests(w); err != nil {
+		t.Fatalf("reconcileImageDigests() error = %v", err)
+	}
+	if wordpress := wordpressImage(w); wordpress != host+"/mysql:5.6@sha256:abc" {
+		t.Errorf("wordpress image = %q, want it pinned", wordpress)
+	}
+	cond = w.Status.Conditions.GetCondition(imageDigestsCondition)
+	if cond == nil || cond.Status != corev1.ConditionTrue {
+		t.Errorf("condition = %+v, want True", cond)
+	}
+}
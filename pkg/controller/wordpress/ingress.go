@@ -0,0 +1,100 @@
+package wordpress
+
+import (
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Ingresses are handled as unstructured objects, as networking.k8s.io/v1 is
+// newer than the client of the operator. The operator still starts on
+// clusters not serving it.
+var ingressGVK = schema.GroupVersionKind{
+	Group:   "networking.k8s.io",
+	Version: "v1",
+	Kind:    "Ingress",
+}
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile Wordpress Ingress
+/////////////////////////////////////////////////////////////////////
+
+// returns the wordpress Ingress object
+func (r *ReconcileWordpress) genWordpressIngress(w *examplev1.Wordpress) *unstructured.Unstructured {
+	ingress := &unstructured.Unstructured{}
+	ingress.SetGroupVersionKind(ingressGVK)
+	ingress.SetName("wordpress")
+	ingress.SetNamespace(w.Namespace)
+	ingress.SetLabels(map[string]string{"app": "wordpress"})
+
+	spec := w.Spec.Ingress
+	if spec == nil {
+		return ingress
+	}
+
+	if len(spec.Annotations) > 0 {
+		annotations := map[string]string{}
+		for key, value := range spec.Annotations {
+			annotations[key] = value
+		}
+		ingress.SetAnnotations(annotations)
+	}
+
+	paths := spec.Paths
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	backend := map[string]interface{}{
+		"service": map[string]interface{}{
+			"name": "wordpress",
+			"port": map[string]interface{}{
+				"number": int64(wordpressServicePort(w)),
+			},
+		},
+	}
+
+	rules := []interface{}{}
+	hosts := []interface{}{}
+	for _, host := range spec.Hosts {
+		httpPaths := []interface{}{}
+		for _, path := range paths {
+			httpPaths = append(httpPaths, map[string]interface{}{
+				"path":     path,
+				"pathType": "Prefix",
+				"backend":  backend,
+			})
+		}
+		rules = append(rules, map[string]interface{}{
+			"host": host,
+			"http": map[string]interface{}{
+				"paths": httpPaths,
+			},
+		})
+		hosts = append(hosts, host)
+	}
+
+	ingressSpec := map[string]interface{}{
+		"rules": rules,
+	}
+	if spec.IngressClassName != "" {
+		ingressSpec["ingressClassName"] = spec.IngressClassName
+	}
+	if secretName := wordpressTLSSecretName(w); secretName != "" {
+		ingressSpec["tls"] = []interface{}{
+			map[string]interface{}{
+				"hosts":      hosts,
+				"secretName": secretName,
+			},
+		}
+	}
+	ingress.Object["spec"] = ingressSpec
+
+	return ingress
+}
+
+// Creates, Updates or Deletes the Wordpress Ingress object
+func (r *ReconcileWordpress) reconcileWordpressIngress(w *examplev1.Wordpress) error {
+	ingress := r.genWordpressIngress(w)
+	return r.reconcileUnstructured(w, ingress, w.Spec.Ingress != nil, "Ingress")
+}
@@ -0,0 +1,99 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenWordpressIngress(t *testing.T) {
+	backend := func(port int64) map[string]interface{} {
+		return map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": "wordpress",
+				"port": map[string]interface{}{"number": port},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		spec        examplev1.WordpressSpec
+		want        map[string]interface{}
+		annotations map[string]string
+	}{
+		{
+			name: "hosts",
+			spec: examplev1.WordpressSpec{
+				Ingress: &examplev1.WordpressIngressSpec{Hosts: []string{"blog.example.com"}},
+			},
+			want: map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{
+						"host": "blog.example.com",
+						"http": map[string]interface{}{
+							"paths": []interface{}{
+								map[string]interface{}{"path": "/", "pathType": "Prefix", "backend": backend(80)},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "class, paths, port, annotations and tls",
+			spec: examplev1.WordpressSpec{
+				Service: &examplev1.WordpressServiceSpec{Port: 8080},
+				Ingress: &examplev1.WordpressIngressSpec{
+					Hosts:            []string{"blog.example.com"},
+					Paths:            []string{"/blog"},
+					IngressClassName: "nginx",
+					Annotations:      map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "64m"},
+					TLSSecretName:    "blog-tls",
+				},
+			},
+			want: map[string]interface{}{
+				"ingressClassName": "nginx",
+				"rules": []interface{}{
+					map[string]interface{}{
+						"host": "blog.example.com",
+						"http": map[string]interface{}{
+							"paths": []interface{}{
+								map[string]interface{}{"path": "/blog", "pathType": "Prefix", "backend": backend(8080)},
+							},
+						},
+					},
+				},
+				"tls": []interface{}{
+					map[string]interface{}{
+						"hosts":      []interface{}{"blog.example.com"},
+						"secretName": "blog-tls",
+					},
+				},
+			},
+			annotations: map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "64m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "default"},
+				Spec:       tt.spec,
+			}
+			ingress := (&ReconcileWordpress{}).genWordpressIngress(w)
+			if ingress.GroupVersionKind() != ingressGVK {
+				t.Errorf("GroupVersionKind() = %v, want %v", ingress.GroupVersionKind(), ingressGVK)
+			}
+			if !reflect.DeepEqual(ingress.Object["spec"], tt.want) {
+				t.Errorf("spec = %v, want %v", ingress.Object["spec"], tt.want)
+			}
+			if !reflect.DeepEqual(ingress.GetAnnotations(), tt.annotations) {
+				t.Errorf("annotations = %v, want %v", ingress.GetAnnotations(), tt.annotations)
+			}
+		})
+	}
+}
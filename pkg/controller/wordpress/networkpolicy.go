@@ -0,0 +1,309 @@
+package wordpress
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// label set on every namespace by Kubernetes
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// tiers of the Jobs copying mysql and wp-content, created by clones and
+// promotions
+var jobTiers = []string{"clone", "promotion"}
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile NetworkPolicies
+/////////////////////////////////////////////////////////////////////
+
+// returns true if spec.networkPolicy is enabled
+func networkPolicyEnabled(w *examplev1.Wordpress) bool {
+	return w.Spec.NetworkPolicy != nil && w.Spec.NetworkPolicy.Enabled
+}
+
+// returns a selector for the clone and promotion Job pods
+func jobPodSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "wordpress"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "tier",
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   jobTiers,
+		}},
+	}
+}
+
+// returns a selector for the namespaces, by their kubernetes.io/metadata.name
+// label set by Kubernetes 1.21 and later
+func namespaceSelector(namespaces []string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      namespaceNameLabel,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   namespaces,
+		}},
+	}
+}
+
+// returns the other namespaces of the clones and promotions copying this
+// site while they are in progress, their Jobs connect to its mysql
+func jobNamespaces(w *examplev1.Wordpress, clones []examplev1.Wordpress, promotions []examplev1.WordpressPromotion) []string {
+	namespaces := []string{}
+	add := func(namespace string) {
+		if namespace != w.Namespace && !contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	for i := range clones {
+		source := clones[i].Spec.CloneFrom
+		if source != nil && source.Namespace == w.Namespace && source.Name == w.Name && cloneInProgress(&clones[i]) {
+			add(clones[i].Namespace)
+		}
+	}
+	for _, p := range promotions {
+		if p.Spec.Source.Namespace != w.Namespace || p.Spec.Source.Name != w.Name {
+			continue
+		}
+		if p.Status.Phase != examplev1.WordpressPromotionCompleted && p.Status.Phase != examplev1.WordpressPromotionFailed {
+			add(p.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// lists the clones and promotions in the watched namespaces and returns the
+// namespaces of the ones copying this site
+func (r *ReconcileWordpress) listJobNamespaces(w *examplev1.Wordpress) ([]string, error) {
+	clones := &examplev1.WordpressList{}
+	err := r.client.List(context.TODO(), clones)
+	if err != nil {
+		return nil, err
+	}
+	promotions := &examplev1.WordpressPromotionList{}
+	err = r.client.List(context.TODO(), promotions)
+	if err != nil {
+		return nil, err
+	}
+	return jobNamespaces(w, clones.Items, promotions.Items), nil
+}
+
+// returns a NetworkPolicyPort for TCP or UDP
+func networkPolicyPort(protocol corev1.Protocol, port int) networkingv1.NetworkPolicyPort {
+	portValue := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{
+		Protocol: &protocol,
+		Port:     &portValue,
+	}
+}
+
+// returns an egress rule allowing DNS lookups
+func dnsEgressRule() networkingv1.NetworkPolicyEgressRule {
+	return networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{
+			networkPolicyPort(corev1.ProtocolUDP, 53),
+			networkPolicyPort(corev1.ProtocolTCP, 53),
+		},
+	}
+}
+
+// returns the mysql NetworkPolicy: ingress from the wordpress pods and the
+// clone and promotion Jobs of this site, and from the Jobs of the clones and
+// promotions in jobNamespaces copying this site
+func (r *ReconcileWordpress) genMysqlNetworkPolicy(w *examplev1.Wordpress, jobNamespaces []string) *networkingv1.NetworkPolicy {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "wordpress-mysql",
+			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: MysqlLabels(),
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{
+					{
+						PodSelector: &metav1.LabelSelector{
+							MatchLabels: WordpressLabels(),
+						},
+					},
+					{
+						PodSelector: jobPodSelector(),
+					},
+				},
+				Ports: []networkingv1.NetworkPolicyPort{
+					networkPolicyPort(corev1.ProtocolTCP, 3306),
+				},
+			}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+
+	if len(jobNamespaces) > 0 {
+		policy.Spec.Ingress[0].From = append(policy.Spec.Ingress[0].From, networkingv1.NetworkPolicyPeer{
+			PodSelector:       jobPodSelector(),
+			NamespaceSelector: namespaceSelector(jobNamespaces),
+		})
+	}
+
+	if w.Spec.NetworkPolicy.RestrictEgress {
+		policy.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{dnsEgressRule()}
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+	}
+
+	// Set Wordpress instance as the owner of the NetworkPolicy.
+	controllerutil.SetControllerReference(w, policy, r.scheme)
+	return policy
+}
+
+// returns the namespaces of the ingress controllers and Gateway allowed to
+// connect to wordpress
+func ingressNamespaces(w *examplev1.Wordpress) []string {
+	namespaces := w.Spec.NetworkPolicy.IngressNamespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{}
+		for _, namespace := range strings.Split(os.Getenv("WORDPRESS_INGRESS_NAMESPACES"), ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+	if w.Spec.Gateway != nil && !contains(namespaces, gatewayNamespace(w)) {
+		namespaces = append(namespaces, gatewayNamespace(w))
+	}
+	return namespaces
+}
+
+// returns the wordpress NetworkPolicy: ingress from the ingress controller
+// namespaces, or from anywhere if the Service is exposed outside the cluster
+// and neither spec.ingress, spec.gateway nor ingressNamespaces is set
+func (r *ReconcileWordpress) genWordpressNetworkPolicy(w *examplev1.Wordpress) *networkingv1.NetworkPolicy {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "wordpress",
+			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: WordpressLabels(),
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{
+					networkPolicyPort(corev1.ProtocolTCP, 80),
+				},
+			}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+
+	// a rule without peers allows traffic from NodePort and LoadBalancer Services,
+	// unless the site is meant to be reached through an ingress controller or Gateway
+	exposed := w.Spec.Service == nil || (w.Spec.Service.Type != "" && w.Spec.Service.Type != corev1.ServiceTypeClusterIP)
+	routed := w.Spec.Ingress != nil || w.Spec.Gateway != nil || len(w.Spec.NetworkPolicy.IngressNamespaces) > 0
+	if !exposed || routed {
+		namespaces := ingressNamespaces(w)
+		if len(namespaces) == 0 {
+			policy.Spec.Ingress = nil
+		} else {
+			policy.Spec.Ingress[0].From = []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: namespaceSelector(namespaces),
+			}}
+		}
+	}
+
+	if w.Spec.NetworkPolicy.RestrictEgress {
+		mysql := networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{{
+				PodSelector: &metav1.LabelSelector{
+					MatchLabels: MysqlLabels(),
+				},
+			}},
+			Ports: []networkingv1.NetworkPolicyPort{
+				networkPolicyPort(corev1.ProtocolTCP, 3306),
+			},
+		}
+		policy.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{mysql, dnsEgressRule()}
+		if len(w.Spec.NetworkPolicy.EgressCIDRs) > 0 {
+			to := []networkingv1.NetworkPolicyPeer{}
+			for _, cidr := range w.Spec.NetworkPolicy.EgressCIDRs {
+				to = append(to, networkingv1.NetworkPolicyPeer{
+					IPBlock: &networkingv1.IPBlock{CIDR: cidr},
+				})
+			}
+			policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{To: to})
+		}
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+	}
+
+	// Set Wordpress instance as the owner of the NetworkPolicy.
+	controllerutil.SetControllerReference(w, policy, r.scheme)
+	return policy
+}
+
+// Creates, Updates or Deletes a NetworkPolicy object
+func (r *ReconcileWordpress) reconcileNetworkPolicy(w *examplev1.Wordpress, name string, gen func() *networkingv1.NetworkPolicy) error {
+	existing := &networkingv1.NetworkPolicy{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: name}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	// delete the NetworkPolicy when spec.networkPolicy is disabled
+	if !networkPolicyEnabled(w) {
+		if found && metav1.IsControlledBy(existing, w) {
+			return r.DeleteObject(existing, "NetworkPolicy")
+		}
+		return nil
+	}
+
+	policy := gen()
+	if !found {
+		return r.CreateObject(policy, "NetworkPolicy")
+	}
+	if equality.Semantic.DeepEqual(existing.Spec, policy.Spec) {
+		return nil
+	}
+	updated := existing.DeepCopy()
+	updated.Spec = policy.Spec
+	updated.Labels = policy.Labels
+	updated.OwnerReferences = policy.OwnerReferences
+	return r.UpdateObject(updated, "NetworkPolicy")
+}
+
+// Creates, Updates or Deletes the mysql and wordpress NetworkPolicy objects
+func (r *ReconcileWordpress) reconcileNetworkPolicies(w *examplev1.Wordpress) error {
+	namespaces := []string{}
+	if networkPolicyEnabled(w) {
+		var err error
+		namespaces, err = r.listJobNamespaces(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := r.reconcileNetworkPolicy(w, "wordpress-mysql", func() *networkingv1.NetworkPolicy {
+		return r.genMysqlNetworkPolicy(w, namespaces)
+	})
+	if err != nil {
+		return err
+	}
+	return r.reconcileNetworkPolicy(w, "wordpress", func() *networkingv1.NetworkPolicy {
+		return r.genWordpressNetworkPolicy(w)
+	})
+}
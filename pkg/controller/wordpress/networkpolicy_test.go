@@ -0,0 +1,344 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func testReconcileWordpress(t *testing.T) *ReconcileWordpress {
+	scheme := runtime.NewScheme()
+	if err := examplev1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &ReconcileWordpress{scheme: scheme}
+}
+
+func TestJobNamespaces(t *testing.T) {
+	w := &examplev1.Wordpress{ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"}}
+
+	clone := func(namespace, sourceNamespace, sourceName string, phase examplev1.WordpressClonePhase) examplev1.Wordpress {
+		c := examplev1.Wordpress{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: namespace},
+			Spec: examplev1.WordpressSpec{
+				CloneFrom: &examplev1.WordpressCloneSource{Name: sourceName, Namespace: sourceNamespace},
+			},
+		}
+		if phase != "" {
+			c.Status.Clone = &examplev1.WordpressCloneStatus{Phase: phase}
+		}
+		return c
+	}
+	promotion := func(namespace, sourceNamespace, sourceName string, phase examplev1.WordpressPromotionPhase) examplev1.WordpressPromotion {
+		return examplev1.WordpressPromotion{
+			ObjectMeta: metav1.ObjectMeta{Name: "promote", Namespace: namespace},
+			Spec: examplev1.WordpressPromotionSpec{
+				Source: examplev1.WordpressReference{Name: sourceName, Namespace: sourceNamespace},
+			},
+			Status: examplev1.WordpressPromotionStatus{Phase: phase},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		clones     []examplev1.Wordpress
+		promotions []examplev1.WordpressPromotion
+		want       []string
+	}{
+		{name: "none", want: []string{}},
+		{
+			name: "clones in progress",
+			clones: []examplev1.Wordpress{
+				clone("staging", "production", "mysite", ""),
+				clone("dev", "production", "mysite", examplev1.WordpressCloneCloning),
+			},
+			want: []string{"dev", "staging"},
+		},
+		{
+			name: "finished clones",
+			clones: []examplev1.Wordpress{
+				clone("staging", "production", "mysite", examplev1.WordpressCloneCompleted),
+				clone("dev", "production", "mysite", examplev1.WordpressCloneFailed),
+			},
+			want: []string{},
+		},
+		{
+			name: "clones of other sites",
+			clones: []examplev1.Wordpress{
+				clone("staging", "production", "othersite", ""),
+				clone("dev", "staging", "mysite", ""),
+				{ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "test"}},
+			},
+			want: []string{},
+		},
+		{
+			name: "promotions in progress",
+			promotions: []examplev1.WordpressPromotion{
+				promotion("live", "production", "mysite", ""),
+				promotion("live", "production", "mysite", examplev1.WordpressPromotionSyncing),
+				promotion("backup", "production", "mysite", examplev1.WordpressPromotionBackingUp),
+			},
+			want: []string{"backup", "live"},
+		},
+		{
+			name: "finished and other promotions",
+			promotions: []examplev1.WordpressPromotion{
+				promotion("live", "production", "mysite", examplev1.WordpressPromotionCompleted),
+				promotion("backup", "production", "mysite", examplev1.WordpressPromotionFailed),
+				promotion("other", "staging", "mysite", ""),
+			},
+			want: []string{},
+		},
+		{
+			name:       "same namespace is left to the pod selector",
+			clones:     []examplev1.Wordpress{clone("production", "production", "mysite", "")},
+			promotions: []examplev1.WordpressPromotion{promotion("production", "production", "mysite", "")},
+			want:       []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobNamespaces(w, tt.clones, tt.promotions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jobNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenMysqlNetworkPolicy(t *testing.T) {
+	r := testReconcileWordpress(t)
+
+	frontend := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: WordpressLabels()}}
+	jobs := networkingv1.NetworkPolicyPeer{PodSelector: jobPodSelector()}
+
+	tests := []struct {
+		name           string
+		restrictEgress bool
+		jobNamespaces  []string
+		wantFrom       []networkingv1.NetworkPolicyPeer
+		wantTypes      []networkingv1.PolicyType
+	}{
+		{
+			name:      "same namespace",
+			wantFrom:  []networkingv1.NetworkPolicyPeer{frontend, jobs},
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+		{
+			name:          "clone and promotion namespaces",
+			jobNamespaces: []string{"dev", "staging"},
+			wantFrom: []networkingv1.NetworkPolicyPeer{frontend, jobs, {
+				PodSelector: jobPodSelector(),
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{
+						Key:      namespaceNameLabel,
+						Operator: metav1.LabelSelectorOpIn,
+						Values:   []string{"dev", "staging"},
+					}},
+				},
+			}},
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+		{
+			name:           "restricted egress",
+			restrictEgress: true,
+			wantFrom:       []networkingv1.NetworkPolicyPeer{frontend, jobs},
+			wantTypes:      []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+				Spec: examplev1.WordpressSpec{
+					NetworkPolicy: &examplev1.WordpressNetworkPolicySpec{Enabled: true, RestrictEgress: tt.restrictEgress},
+				},
+			}
+			policy := r.genMysqlNetworkPolicy(w, tt.jobNamespaces)
+
+			if !reflect.DeepEqual(policy.Spec.PodSelector.MatchLabels, MysqlLabels()) {
+				t.Errorf("podSelector = %v, want %v", policy.Spec.PodSelector.MatchLabels, MysqlLabels())
+			}
+			if len(policy.Spec.Ingress) != 1 {
+				t.Fatalf("ingress = %+v, want one rule", policy.Spec.Ingress)
+			}
+			if !reflect.DeepEqual(policy.Spec.Ingress[0].From, tt.wantFrom) {
+				t.Errorf("from = %+v, want %+v", policy.Spec.Ingress[0].From, tt.wantFrom)
+			}
+			if !reflect.DeepEqual(policy.Spec.PolicyTypes, tt.wantTypes) {
+				t.Errorf("policyTypes = %v, want %v", policy.Spec.PolicyTypes, tt.wantTypes)
+			}
+			if tt.restrictEgress && !reflect.DeepEqual(policy.Spec.Egress, []networkingv1.NetworkPolicyEgressRule{dnsEgressRule()}) {
+				t.Errorf("egress = %+v, want DNS only", policy.Spec.Egress)
+			}
+			if !metav1.IsControlledBy(policy, w) {
+				t.Errorf("NetworkPolicy is not controlled by the Wordpress instance")
+			}
+		})
+	}
+}
+
+func TestGenWordpressNetworkPolicy(t *testing.T) {
+	r := testReconcileWordpress(t)
+
+	namespaces := func(names ...string) []networkingv1.NetworkPolicyPeer {
+		return []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      namespaceNameLabel,
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   names,
+				}},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name         string
+		env          string
+		service      *examplev1.WordpressServiceSpec
+		ingress      *examplev1.WordpressIngressSpec
+		gateway      *examplev1.WordpressGatewaySpec
+		policy       examplev1.WordpressNetworkPolicySpec
+		wantIngress  bool
+		wantFrom     []networkingv1.NetworkPolicyPeer
+		wantEgress   int
+		wantEgressTo []networkingv1.NetworkPolicyPeer
+	}{
+		{
+			name:        "default load balancer allows anywhere",
+			env:         "ingress-nginx",
+			wantIngress: true,
+		},
+		{
+			name:        "node port allows anywhere",
+			env:         "ingress-nginx",
+			service:     &examplev1.WordpressServiceSpec{Type: corev1.ServiceTypeNodePort},
+			wantIngress: true,
+		},
+		{
+			name:        "cluster IP allows the ingress namespaces from the environment",
+			env:         "ingress-nginx, traefik",
+			service:     &examplev1.WordpressServiceSpec{Type: corev1.ServiceTypeClusterIP},
+			wantIngress: true,
+			wantFrom:    namespaces("ingress-nginx", "traefik"),
+		},
+		{
+			name:        "spec ingress namespaces override the environment",
+			env:         "ingress-nginx",
+			service:     &examplev1.WordpressServiceSpec{Type: corev1.ServiceTypeClusterIP},
+			policy:      examplev1.WordpressNetworkPolicySpec{IngressNamespaces: []string{"contour"}},
+			wantIngress: true,
+			wantFrom:    namespaces("contour"),
+		},
+		{
+			name:    "gateway namespace is added",
+			env:     "ingress-nginx",
+			service: &examplev1.WordpressServiceSpec{Type: corev1.ServiceTypeClusterIP},
+			gateway: &examplev1.WordpressGatewaySpec{
+				GatewayRef: examplev1.WordpressGatewayReference{Name: "gateway", Namespace: "gateways"},
+			},
+			wantIngress: true,
+			wantFrom:    namespaces("ingress-nginx", "gateways"),
+		},
+		{
+			name:        "ingress restricts the default load balancer",
+			env:         "ingress-nginx",
+			ingress:     &examplev1.WordpressIngressSpec{Hosts: []string{"blog.example.com"}},
+			wantIngress: true,
+			wantFrom:    namespaces("ingress-nginx"),
+		},
+		{
+			name:    "gateway restricts a node port",
+			service: &examplev1.WordpressServiceSpec{Type: corev1.ServiceTypeNodePort},
+			gateway: &examplev1.WordpressGatewaySpec{
+				GatewayRef: examplev1.WordpressGatewayReference{Name: "gateway", Namespace: "gateways"},
+			},
+			wantIngress: true,
+			wantFrom:    namespaces("gateways"),
+		},
+		{
+			name:        "spec ingress namespaces restrict the default load balancer",
+			env:         "ingress-nginx",
+			policy:      examplev1.WordpressNetworkPolicySpec{IngressNamespaces: []string{"contour"}},
+			wantIngress: true,
+			wantFrom:    namespaces("contour"),
+		},
+		{
+			name:    "ingress without ingress namespaces denies ingress",
+			ingress: &examplev1.WordpressIngressSpec{Hosts: []string{"blog.example.com"}},
+		},
+		{
+			name:    "cluster IP without ingress namespaces denies ingress",
+			service: &examplev1.WordpressServiceSpec{Type: corev1.ServiceTypeClusterIP},
+		},
+		{
+			name:        "restricted egress",
+			policy:      examplev1.WordpressNetworkPolicySpec{RestrictEgress: true},
+			wantIngress: true,
+			wantEgress:  2,
+		},
+		{
+			name:         "restricted egress with CIDRs",
+			policy:       examplev1.WordpressNetworkPolicySpec{RestrictEgress: true, EgressCIDRs: []string{"203.0.113.0/24"}},
+			wantIngress:  true,
+			wantEgress:   3,
+			wantEgressTo: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "203.0.113.0/24"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WORDPRESS_INGRESS_NAMESPACES", tt.env)
+			policy := tt.policy
+			policy.Enabled = true
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+				Spec: examplev1.WordpressSpec{
+					Service:       tt.service,
+					Ingress:       tt.ingress,
+					Gateway:       tt.gateway,
+					NetworkPolicy: &policy,
+				},
+			}
+			got := r.genWordpressNetworkPolicy(w)
+
+			if !reflect.DeepEqual(got.Spec.PodSelector.MatchLabels, WordpressLabels()) {
+				t.Errorf("podSelector = %v, want %v", got.Spec.PodSelector.MatchLabels, WordpressLabels())
+			}
+			if !tt.wantIngress {
+				if got.Spec.Ingress != nil {
+					t.Errorf("ingress = %+v, want none", got.Spec.Ingress)
+				}
+			} else {
+				if len(got.Spec.Ingress) != 1 {
+					t.Fatalf("ingress = %+v, want one rule", got.Spec.Ingress)
+				}
+				if !reflect.DeepEqual(got.Spec.Ingress[0].From, tt.wantFrom) {
+					t.Errorf("from = %+v, want %+v", got.Spec.Ingress[0].From, tt.wantFrom)
+				}
+			}
+			if len(got.Spec.Egress) != tt.wantEgress {
+				t.Fatalf("egress = %+v, want %d rules", got.Spec.Egress, tt.wantEgress)
+			}
+			if tt.wantEgress > 0 {
+				if !reflect.DeepEqual(got.Spec.Egress[0].To[0].PodSelector.MatchLabels, MysqlLabels()) {
+					t.Errorf("egress[0] = %+v, want mysql", got.Spec.Egress[0])
+				}
+				if !reflect.DeepEqual(got.Spec.PolicyTypes, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}) {
+					t.Errorf("policyTypes = %v, want Ingress and Egress", got.Spec.PolicyTypes)
+				}
+			}
+			if tt.wantEgressTo != nil && !reflect.DeepEqual(got.Spec.Egress[2].To, tt.wantEgressTo) {
+				t.Errorf("egress[2].to = %+v, want %+v", got.Spec.Egress[2].To, tt.wantEgressTo)
+			}
+		})
+	}
+}
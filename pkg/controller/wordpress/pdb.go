@@ -0,0 +1,139 @@
+package wordpress
+
+import (
+	"context"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// PodDisruptionBudgets are handled as unstructured objects of an optional API
+// version, policy/v1 is served since Kubernetes 1.21
+var pdbGVK = schema.GroupVersionKind{
+	Group:   "policy",
+	Version: "v1",
+	Kind:    "PodDisruptionBudget",
+}
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile PodDisruptionBudgets
+/////////////////////////////////////////////////////////////////////
+
+// returns the minAvailable or maxUnavailable of the PodDisruptionBudget of a
+// tier, from spec or from defaultSpec, or nil if it is not wanted
+func podDisruptionBudgetSpec(spec, defaultSpec *examplev1.WordpressPodDisruptionBudgetSpec) *examplev1.WordpressPodDisruptionBudgetSpec {
+	if spec != nil && spec.Disabled {
+		return nil
+	}
+	if spec == nil || (spec.MinAvailable == nil && spec.MaxUnavailable == nil) {
+		return defaultSpec
+	}
+	return spec
+}
+
+// returns the default PodDisruptionBudgets of mysql and wordpress, nil for no
+// PodDisruptionBudget. mysql only has one pod, which a budget would either not
+// protect or keep from being drained, so it has none. One wordpress pod at a
+// time may be evicted if there may be more than one
+func defaultPodDisruptionBudgetSpecs(w *examplev1.Wordpress) (mysql, wordpress *examplev1.WordpressPodDisruptionBudgetSpec) {
+	if w.MaxWordpressReplicas() > 1 {
+		one := intstr.FromInt(1)
+		wordpress = &examplev1.WordpressPodDisruptionBudgetSpec{MaxUnavailable: &one}
+	}
+	return nil, wordpress
+}
+
+// returns the PodDisruptionBudget for the pods with labels, with the
+// minAvailable or maxUnavailable of spec
+func (r *ReconcileWordpress) genPodDisruptionBudget(w *examplev1.Wordpress, name string, labels map[string]string,
+	spec *examplev1.WordpressPodDisruptionBudgetSpec) *unstructured.Unstructured {
+	pdb := &unstructured.Unstructured{}
+	pdb.SetGroupVersionKind(pdbGVK)
+	pdb.SetName(name)
+	pdb.SetNamespace(w.Namespace)
+	pdb.SetLabels(map[string]string{"app": "wordpress"})
+
+	matchLabels := map[string]interface{}{}
+	for key, value := range labels {
+		matchLabels[key] = value
+	}
+	pdbSpec := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": matchLabels,
+		},
+	}
+	if spec != nil && spec.MinAvailable != nil {
+		pdbSpec["minAvailable"] = intOrStringValue(*spec.MinAvailable)
+	}
+	if spec != nil && spec.MaxUnavailable != nil {
+		pdbSpec["maxUnavailable"] = intOrStringValue(*spec.MaxUnavailable)
+	}
+	pdb.Object["spec"] = pdbSpec
+
+	return pdb
+}
+
+// returns the unstructured value of an IntOrString
+func intOrStringValue(value intstr.IntOrString) interface{} {
+	if value.Type == intstr.String {
+		return value.StrVal
+	}
+	return int64(value.IntVal)
+}
+
+// returns false if the cluster doesn't serve policy/v1 PodDisruptionBudgets
+func (r *ReconcileWordpress) podDisruptionBudgetsServed(w *examplev1.Wordpress) (bool, error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(pdbGVK)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: "wordpress"}, existing)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	} else if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// Creates, Updates or Deletes the mysql and wordpress PodDisruptionBudget
+// objects. Returns false if policy/v1 is not served and they are skipped
+func (r *ReconcileWordpress) reconcilePodDisruptionBudgets(w *examplev1.Wordpress) (bool, error) {
+	var mysqlSpec *examplev1.WordpressPodDisruptionBudgetSpec
+	if w.Spec.Mysql != nil {
+		mysqlSpec = w.Spec.Mysql.PodDisruptionBudget
+	}
+	err := mysqlSpec.Validate("spec.mysql.podDisruptionBudget")
+	if err != nil {
+		return false, err
+	}
+	var wordpressSpec *examplev1.WordpressPodDisruptionBudgetSpec
+	if w.Spec.Wordpress != nil {
+		wordpressSpec = w.Spec.Wordpress.PodDisruptionBudget
+	}
+	err = wordpressSpec.Validate("spec.wordpress.podDisruptionBudget")
+	if err != nil {
+		return false, err
+	}
+
+	served, err := r.podDisruptionBudgetsServed(w)
+	if err != nil || !served {
+		return false, err
+	}
+
+	mysqlDefault, wordpressDefault := defaultPodDisruptionBudgetSpecs(w)
+	mysqlSpec = podDisruptionBudgetSpec(mysqlSpec, mysqlDefault)
+	pdb := r.genPodDisruptionBudget(w, "wordpress-mysql", MysqlLabels(), mysqlSpec)
+	err = r.reconcileUnstructured(w, pdb, mysqlSpec != nil, "PodDisruptionBudget")
+	if err != nil {
+		return false, err
+	}
+
+	wordpressSpec = podDisruptionBudgetSpec(wordpressSpec, wordpressDefault)
+	pdb = r.genPodDisruptionBudget(w, "wordpress", WordpressLabels(), wordpressSpec)
+	return true, r.reconcileUnstructured(w, pdb, wordpressSpec != nil, "PodDisruptionBudget")
+}
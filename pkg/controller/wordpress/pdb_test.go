@@ -0,0 +1,135 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestPodDisruptionBudgetSpecs(t *testing.T) {
+	int32Ptr := func(n int32) *int32 { return &n }
+	intOrString := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+	one := intOrString(intstr.FromInt(1))
+
+	tests := []struct {
+		name          string
+		mysql         *examplev1.WordpressMysqlSpec
+		wordpress     *examplev1.WordpressFrontendSpec
+		wantMysql     *examplev1.WordpressPodDisruptionBudgetSpec
+		wantWordpress *examplev1.WordpressPodDisruptionBudgetSpec
+	}{
+		{name: "defaults"},
+		{name: "one replica", wordpress: &examplev1.WordpressFrontendSpec{Replicas: int32Ptr(1)}},
+		{name: "scaled to 0", wordpress: &examplev1.WordpressFrontendSpec{Replicas: int32Ptr(0)}},
+		{
+			name:          "several replicas",
+			wordpress:     &examplev1.WordpressFrontendSpec{Replicas: int32Ptr(3)},
+			wantWordpress: &examplev1.WordpressPodDisruptionBudgetSpec{MaxUnavailable: one},
+		},
+		{
+			name:          "autoscaling to several replicas",
+			wordpress:     &examplev1.WordpressFrontendSpec{Autoscaling: &examplev1.WordpressAutoscalingSpec{MaxReplicas: 4}},
+			wantWordpress: &examplev1.WordpressPodDisruptionBudgetSpec{MaxUnavailable: one},
+		},
+		{
+			name:      "autoscaling to one replica",
+			wordpress: &examplev1.WordpressFrontendSpec{Replicas: int32Ptr(3), Autoscaling: &examplev1.WordpressAutoscalingSpec{MaxReplicas: 1}},
+		},
+		{
+			name: "explicit budgets",
+			mysql: &examplev1.WordpressMysqlSpec{
+				PodDisruptionBudget: &examplev1.WordpressPodDisruptionBudgetSpec{MinAvailable: one},
+			},
+			wordpress: &examplev1.WordpressFrontendSpec{
+				PodDisruptionBudget: &examplev1.WordpressPodDisruptionBudgetSpec{MinAvailable: intOrString(intstr.FromString("75%"))},
+			},
+			wantMysql:     &examplev1.WordpressPodDisruptionBudgetSpec{MinAvailable: one},
+			wantWordpress: &examplev1.WordpressPodDisruptionBudgetSpec{MinAvailable: intOrString(intstr.FromString("75%"))},
+		},
+		{
+			name: "disabled",
+			wordpress: &examplev1.WordpressFrontendSpec{
+				Replicas:            int32Ptr(3),
+				PodDisruptionBudget: &examplev1.WordpressPodDisruptionBudgetSpec{MaxUnavailable: one, Disabled: true},
+			},
+		},
+		{
+			name: "empty budget uses the default",
+			mysql: &examplev1.WordpressMysqlSpec{
+				PodDisruptionBudget: &examplev1.WordpressPodDisruptionBudgetSpec{},
+			},
+			wordpress: &examplev1.WordpressFrontendSpec{
+				Replicas:            int32Ptr(2),
+				PodDisruptionBudget: &examplev1.WordpressPodDisruptionBudgetSpec{},
+			},
+			wantWordpress: &examplev1.WordpressPodDisruptionBudgetSpec{MaxUnavailable: one},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{Spec: examplev1.WordpressSpec{Mysql: tt.mysql, Wordpress: tt.wordpress}}
+			mysqlDefault, wordpressDefault := defaultPodDisruptionBudgetSpecs(w)
+
+			var mysqlSpec, wordpressSpec *examplev1.WordpressPodDisruptionBudgetSpec
+			if tt.mysql != nil {
+				mysqlSpec = tt.mysql.PodDisruptionBudget
+			}
+			if tt.wordpress != nil {
+				wordpressSpec = tt.wordpress.PodDisruptionBudget
+			}
+			if got := podDisruptionBudgetSpec(mysqlSpec, mysqlDefault); !reflect.DeepEqual(got, tt.wantMysql) {
+				t.Errorf("mysql = %+v, want %+v", got, tt.wantMysql)
+			}
+			if got := podDisruptionBudgetSpec(wordpressSpec, wordpressDefault); !reflect.DeepEqual(got, tt.wantWordpress) {
+				t.Errorf("wordpress = %+v, want %+v", got, tt.wantWordpress)
+			}
+		})
+	}
+}
+
+func TestGenPodDisruptionBudget(t *testing.T) {
+	r := testReconcileWordpress(t)
+	w := &examplev1.Wordpress{}
+	w.Name = "mysite"
+	w.Namespace = "production"
+
+	maxUnavailable := intstr.FromInt(1)
+	minAvailable := intstr.FromString("75%")
+
+	tests := []struct {
+		name string
+		spec *examplev1.WordpressPodDisruptionBudgetSpec
+		want map[string]interface{}
+	}{
+		{
+			name: "maxUnavailable",
+			spec: &examplev1.WordpressPodDisruptionBudgetSpec{MaxUnavailable: &maxUnavailable},
+			want: map[string]interface{}{"maxUnavailable": int64(1)},
+		},
+		{
+			name: "minAvailable percentage",
+			spec: &examplev1.WordpressPodDisruptionBudgetSpec{MinAvailable: &minAvailable},
+			want: map[string]interface{}{"minAvailable": "75%"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdb := r.genPodDisruptionBudget(w, "wordpress", WordpressLabels(), tt.spec)
+			if pdb.GroupVersionKind() != pdbGVK {
+				t.Errorf("gvk = %v, want %v", pdb.GroupVersionKind(), pdbGVK)
+			}
+			spec := pdb.Object["spec"].(map[string]interface{})
+			tt.want["selector"] = map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "wordpress", "tier": "frontend"},
+			}
+			if !reflect.DeepEqual(spec, tt.want) {
+				t.Errorf("spec = %v, want %v", spec, tt.want)
+			}
+		})
+	}
+}
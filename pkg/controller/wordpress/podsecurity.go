@@ -0,0 +1,232 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// namespace label selecting the enforced Pod Security Standard level
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+	// deprecated annotations selecting the seccomp profile of the pod and of
+	// a container, still checked by the baseline level
+	seccompPodAnnotation             = "seccomp.security.alpha.kubernetes.io/pod"
+	seccompContainerAnnotationPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+)
+
+// Pod Security Standard levels, from least to most restrictive
+var podSecurityLevels = []string{"privileged", "baseline", "restricted"}
+
+// capabilities the baseline level allows adding
+var baselineCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true,
+	"MKNOD": true, "NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true,
+	"SYS_CHROOT": true,
+}
+
+/////////////////////////////////////////////////////////////////////
+// Pod Security Standards
+/////////////////////////////////////////////////////////////////////
+
+// returns the index of a level in podSecurityLevels, privileged if unknown
+func podSecurityLevelIndex(level string) int {
+	for i, l := range podSecurityLevels {
+		if l == level {
+			return i
+		}
+	}
+	return 0
+}
+
+// returns the settings of a pod template violating the baseline and the
+// restricted levels, a subset of the Pod Security Standards checks
+func podSecurityViolations(template *corev1.PodTemplateSpec) ([]string, []string) {
+	baseline := []string{}
+	restricted := []string{}
+	pod := &template.Spec
+
+	if pod.HostNetwork || pod.HostPID || pod.HostIPC {
+		baseline = append(baseline, "uses host namespaces")
+	}
+	for _, volume := range pod.Volumes {
+		source := volume.VolumeSource
+		if source.HostPath != nil {
+			baseline = append(baseline, fmt.Sprintf("volume %s is a hostPath", volume.Name))
+		} else if source.ConfigMap == nil && source.CSI == nil && source.DownwardAPI == nil && source.EmptyDir == nil &&
+			source.PersistentVolumeClaim == nil && source.Projected == nil && source.Secret == nil {
+			restricted = append(restricted, fmt.Sprintf("volume %s has a restricted volume type", volume.Name))
+		}
+	}
+
+	podAnnotation := template.Annotations[seccompPodAnnotation]
+	var podProfile *corev1.SeccompProfile
+	podNonRoot := false
+	if sc := pod.SecurityContext; sc != nil {
+		podProfile = sc.SeccompProfile
+		podNonRoot = sc.RunAsNonRoot != nil && *sc.RunAsNonRoot
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			restricted = append(restricted, "runs as uid 0")
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, pod.InitContainers...), pod.Containers...)
+	for _, container := range containers {
+		name := fmt.Sprintf("container %s", container.Name)
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		if sc.Privileged != nil && *sc.Privileged {
+			baseline = append(baseline, fmt.Sprintf("%s is privileged", name))
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				baseline = append(baseline, fmt.Sprintf("%s uses host port %d", name, port.HostPort))
+			}
+		}
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			baseline = append(baseline, fmt.Sprintf("%s unmasks /proc", name))
+		}
+
+		// the baseline level forbids the unconfined profile, the restricted
+		// level requires the RuntimeDefault or a Localhost profile, set with
+		// the seccompProfile field as the annotations don't count
+		annotation := podAnnotation
+		if containerAnnotation, ok := template.Annotations[seccompContainerAnnotationPrefix+container.Name]; ok {
+			annotation = containerAnnotation
+		}
+		profile := podProfile
+		if sc.SeccompProfile != nil {
+			profile = sc.SeccompProfile
+		}
+		if annotation == "unconfined" || (profile != nil && profile.Type == corev1.SeccompProfileTypeUnconfined) {
+			baseline = append(baseline, fmt.Sprintf("%s has an unconfined seccomp profile", name))
+		} else if profile == nil {
+			restricted = append(restricted, fmt.Sprintf("%s doesn't set a seccompProfile", name))
+		}
+
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			restricted = append(restricted, fmt.Sprintf("%s allows privilege escalation", name))
+		}
+		nonRoot := podNonRoot
+		if sc.RunAsNonRoot != nil {
+			nonRoot = *sc.RunAsNonRoot
+		}
+		if !nonRoot {
+			restricted = append(restricted, fmt.Sprintf("%s doesn't set runAsNonRoot", name))
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			restricted = append(restricted, fmt.Sprintf("%s runs as uid 0", name))
+		}
+
+		dropsAll := false
+		added := []string{}
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Drop {
+				dropsAll = dropsAll || capability == "ALL"
+			}
+			for _, capability := range sc.Capabilities.Add {
+				if !baselineCapabilities[capability] {
+					baseline = append(baseline, fmt.Sprintf("%s adds capability %s", name, capability))
+				} else if capability != "NET_BIND_SERVICE" {
+					added = append(added, string(capability))
+				}
+			}
+		}
+		if !dropsAll {
+			restricted = append(restricted, fmt.Sprintf("%s doesn't drop ALL capabilities", name))
+		}
+		if len(added) > 0 {
+			restricted = append(restricted, fmt.Sprintf("%s adds capabilities %s", name, strings.Join(added, ", ")))
+		}
+	}
+	return baseline, restricted
+}
+
+// returns the level met by the pods of the deployments and the violations
+// of the level enforced on the namespace
+func genPodSecurityStatus(deployments []*appsv1.Deployment, enforce string) *examplev1.WordpressPodSecurityStatus {
+	status := &examplev1.WordpressPodSecurityStatus{
+		Level:   "restricted",
+		Enforce: enforce,
+	}
+	enforced := podSecurityLevelIndex(enforce)
+
+	for _, deployment := range deployments {
+		baseline, restricted := podSecurityViolations(&deployment.Spec.Template)
+		level := "restricted"
+		if len(baseline) > 0 {
+			level = "privileged"
+		} else if len(restricted) > 0 {
+			level = "baseline"
+		}
+		if podSecurityLevelIndex(level) < podSecurityLevelIndex(status.Level) {
+			status.Level = level
+		}
+
+		violations := []string{}
+		if enforced >= podSecurityLevelIndex("baseline") {
+			violations = append(violations, baseline...)
+		}
+		if enforced >= podSecurityLevelIndex("restricted") {
+			violations = append(violations, restricted...)
+		}
+		for _, violation := range violations {
+			status.Warnings = append(status.Warnings, fmt.Sprintf("%s: %s", deployment.Name, violation))
+		}
+	}
+	return status
+}
+
+// update the Pod Security Standard level of the mysql and wordpress pods in
+// the status, warning about settings not allowed by the namespace
+func (r *ReconcileWordpress) updatePodSecurityStatus(w *examplev1.Wordpress) error {
+	// read uncached, the operator only watches namespaced objects
+	namespace := &corev1.Namespace{}
+	enforce := ""
+	err := r.apiReader.Get(context.TODO(), types.NamespacedName{Name: w.Namespace}, namespace)
+	if errors.IsForbidden(err) {
+		r.logger.Info("Not allowed to read the namespace, assuming no enforced Pod Security Standard")
+	} else if err != nil {
+		return err
+	} else {
+		enforce = namespace.Labels[podSecurityEnforceLabel]
+	}
+
+	deployments := []*appsv1.Deployment{}
+	for _, name := range []string{"wordpress-mysql", "wordpress"} {
+		deployment := &appsv1.Deployment{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: name}, deployment)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		deployments = append(deployments, deployment)
+	}
+
+	var podSecurity *examplev1.WordpressPodSecurityStatus
+	if len(deployments) > 0 {
+		podSecurity = genPodSecurityStatus(deployments, enforce)
+	}
+	if equality.Semantic.DeepEqual(w.Status.PodSecurity, podSecurity) {
+		return nil
+	}
+	w.Status.PodSecurity = podSecurity
+	err = r.client.Status().Update(context.TODO(), w)
+	if err != nil {
+		r.logger.Error(err, "Failed to update wordpress Pod Security Status")
+	}
+	return err
+}
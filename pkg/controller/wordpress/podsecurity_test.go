@@ -0,0 +1,260 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// returns a pod template meeting the restricted level
+func hardenedPodTemplate() *corev1.PodTemplateSpec {
+	nonRoot := true
+	escalation := false
+	return &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{},
+		},
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   &nonRoot,
+				SeccompProfile: seccompRuntimeDefault(),
+			},
+			Containers: []corev1.Container{{
+				Name: "app",
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &escalation,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}},
+			Volumes: []corev1.Volume{{
+				Name:         "data",
+				VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+			}},
+		},
+	}
+}
+
+func TestPodSecurityViolations(t *testing.T) {
+	truePtr := func() *bool { b := true; return &b }
+	uid := func(n int64) *int64 { return &n }
+	unmasked := corev1.UnmaskedProcMount
+	unconfined := &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}
+
+	tests := []struct {
+		name           string
+		modify         func(*corev1.PodTemplateSpec)
+		wantBaseline   []string
+		wantRestricted []string
+	}{
+		{
+			name:           "hardened",
+			wantBaseline:   []string{},
+			wantRestricted: []string{},
+		},
+		{
+			name: "host namespaces",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.HostPID = true
+			},
+			wantBaseline:   []string{"uses host namespaces"},
+			wantRestricted: []string{},
+		},
+		{
+			name: "volume types",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Volumes = append(template.Spec.Volumes,
+					corev1.Volume{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var"}}},
+					corev1.Volume{Name: "nfs", VolumeSource: corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{Server: "nfs", Path: "/"}}},
+					corev1.Volume{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				)
+			},
+			wantBaseline:   []string{"volume host is a hostPath"},
+			wantRestricted: []string{"volume nfs has a restricted volume type"},
+		},
+		{
+			name: "privileged container",
+			modify: func(template *corev1.PodTemplateSpec) {
+				container := &template.Spec.Containers[0]
+				container.SecurityContext.Privileged = truePtr()
+				container.SecurityContext.ProcMount = &unmasked
+				container.SecurityContext.Capabilities.Add = []corev1.Capability{"SYS_ADMIN"}
+				container.Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 8080}}
+			},
+			wantBaseline: []string{
+				"container app is privileged",
+				"container app uses host port 8080",
+				"container app unmasks /proc",
+				"container app adds capability SYS_ADMIN",
+			},
+			wantRestricted: []string{},
+		},
+		{
+			name: "unconfined seccomp profile",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.SecurityContext.SeccompProfile = unconfined
+			},
+			wantBaseline:   []string{"container app has an unconfined seccomp profile"},
+			wantRestricted: []string{},
+		},
+		{
+			name: "unconfined seccomp annotation",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Annotations[seccompPodAnnotation] = "unconfined"
+			},
+			wantBaseline:   []string{"container app has an unconfined seccomp profile"},
+			wantRestricted: []string{},
+		},
+		{
+			name: "container seccomp profile overrides the pod",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.SecurityContext.SeccompProfile = unconfined
+				template.Spec.Containers[0].SecurityContext.SeccompProfile = seccompRuntimeDefault()
+			},
+			wantBaseline:   []string{},
+			wantRestricted: []string{},
+		},
+		{
+			name: "seccomp annotation doesn't count",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.SecurityContext.SeccompProfile = nil
+				template.Annotations[seccompPodAnnotation] = "runtime/default"
+			},
+			wantBaseline:   []string{},
+			wantRestricted: []string{"container app doesn't set a seccompProfile"},
+		},
+		{
+			name: "default security context",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.SecurityContext = nil
+				template.Spec.Containers[0].SecurityContext = nil
+			},
+			wantBaseline: []string{},
+			wantRestricted: []string{
+				"container app doesn't set a seccompProfile",
+				"container app allows privilege escalation",
+				"container app doesn't set runAsNonRoot",
+				"container app doesn't drop ALL capabilities",
+			},
+		},
+		{
+			name: "root user",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.SecurityContext.RunAsUser = uid(0)
+				template.Spec.Containers[0].SecurityContext.RunAsUser = uid(0)
+			},
+			wantBaseline:   []string{},
+			wantRestricted: []string{"runs as uid 0", "container app runs as uid 0"},
+		},
+		{
+			name: "container runAsNonRoot overrides the pod",
+			modify: func(template *corev1.PodTemplateSpec) {
+				nonRoot := false
+				template.Spec.Containers[0].SecurityContext.RunAsNonRoot = &nonRoot
+			},
+			wantBaseline:   []string{},
+			wantRestricted: []string{"container app doesn't set runAsNonRoot"},
+		},
+		{
+			name: "baseline capabilities",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers[0].SecurityContext.Capabilities.Add = wordpressCapabilities
+			},
+			wantBaseline:   []string{},
+			wantRestricted: []string{"container app adds capabilities CHOWN, DAC_OVERRIDE, FOWNER, KILL, SETGID, SETUID"},
+		},
+		{
+			name: "init containers",
+			modify: func(template *corev1.PodTemplateSpec) {
+				template.Spec.InitContainers = []corev1.Container{{Name: "init", SecurityContext: &corev1.SecurityContext{Privileged: truePtr()}}}
+			},
+			wantBaseline: []string{"container init is privileged"},
+			wantRestricted: []string{
+				"container init allows privilege escalation",
+				"container init doesn't drop ALL capabilities",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := hardenedPodTemplate()
+			if tt.modify != nil {
+				tt.modify(template)
+			}
+			baseline, restricted := podSecurityViolations(template)
+			if !reflect.DeepEqual(baseline, tt.wantBaseline) {
+				t.Errorf("baseline = %q, want %q", baseline, tt.wantBaseline)
+			}
+			if !reflect.DeepEqual(restricted, tt.wantRestricted) {
+				t.Errorf("restricted = %q, want %q", restricted, tt.wantRestricted)
+			}
+		})
+	}
+}
+
+func TestGenPodSecurityStatus(t *testing.T) {
+	hardened := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "wordpress-mysql"}}
+	hardened.Spec.Template = *hardenedPodTemplate()
+	root := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "wordpress"}}
+	root.Spec.Template = *hardenedPodTemplate()
+	root.Spec.Template.Spec.SecurityContext.RunAsNonRoot = nil
+
+	tests := []struct {
+		name         string
+		deployments  []*appsv1.Deployment
+		enforce      string
+		wantLevel    string
+		wantWarnings []string
+	}{
+		{name: "restricted", deployments: []*appsv1.Deployment{hardened}, enforce: "restricted", wantLevel: "restricted"},
+		{name: "not enforced", deployments: []*appsv1.Deployment{hardened, root}, wantLevel: "baseline"},
+		{name: "baseline enforced", deployments: []*appsv1.Deployment{hardened, root}, enforce: "baseline", wantLevel: "baseline"},
+		{
+			name:         "restricted enforced",
+			deployments:  []*appsv1.Deployment{hardened, root},
+			enforce:      "restricted",
+			wantLevel:    "baseline",
+			wantWarnings: []string{"wordpress: container app doesn't set runAsNonRoot"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := genPodSecurityStatus(tt.deployments, tt.enforce)
+			if status.Level != tt.wantLevel {
+				t.Errorf("level = %q, want %q", status.Level, tt.wantLevel)
+			}
+			if !reflect.DeepEqual(status.Warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", status.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestGeneratedPodSecurityLevels(t *testing.T) {
+	r := testReconcileWordpress(t)
+	w := &examplev1.Wordpress{ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"}}
+
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		wantLevel  string
+	}{
+		{name: "mysql", deployment: r.genMysqlDeployment(w), wantLevel: "restricted"},
+		{name: "wordpress", deployment: r.genWordpressDeployment(w), wantLevel: "baseline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := genPodSecurityStatus([]*appsv1.Deployment{tt.deployment}, "")
+			if status.Level != tt.wantLevel {
+				t.Errorf("level = %q, want %q", status.Level, tt.wantLevel)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+
+	condv1 "github.com/operator-framework/operator-sdk/pkg/status"
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// podTemplatePatchError is returned by patchPodTemplate when the patch can't
+// be applied to the generated pod template
+type podTemplatePatchError struct {
+	message string
+}
+
+func (e podTemplatePatchError) Error() string {
+	return e.message
+}
+
+/////////////////////////////////////////////////////////////////////
+// Pod Template Patches
+/////////////////////////////////////////////////////////////////////
+
+// applies the podTemplatePatch of the spec over the pod template of the
+// deployment, which must still be selected by the deployment
+func patchPodTemplate(deployment *appsv1.Deployment, field string, patch *runtime.RawExtension) error {
+	err := examplev1.ApplyPodTemplatePatch(&deployment.Spec.Template, patch)
+	if err != nil {
+		return podTemplatePatchError{message: fmt.Sprintf("%s: %v", field, err)}
+	}
+
+	selector := labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels)
+	if !selector.Matches(labels.Set(deployment.Spec.Template.Labels)) {
+		return podTemplatePatchError{message: fmt.Sprintf("%s must not change the labels %s", field, selector.String())}
+	}
+	return nil
+}
+
+// sets the Ready condition to False if err is a podTemplatePatchError, as the
+// Deployment is not updated until the patch is fixed, and returns err
+func (r *ReconcileWordpress) reportPodTemplatePatchError(w *examplev1.Wordpress, err error) error {
+	if _, ok := err.(podTemplatePatchError); !ok {
+		return err
+	}
+
+	cond := condv1.Condition{
+		Type:               readyCondition,
+		Status:             corev1.ConditionFalse,
+		Reason:             condv1.ConditionReason("InvalidPodTemplatePatch"),
+		Message:            err.Error(),
+		LastTransitionTime: metav1.Now(),
+	}
+	if w.Status.Conditions.SetCondition(cond) {
+		if updateErr := r.client.Status().Update(context.TODO(), w); updateErr != nil {
+			r.logger.Error(updateErr, "Failed to update wordpress Status")
+		}
+	}
+	return err
+}
+
+// applies spec.mysql.podTemplatePatch to the mysql deployment
+func patchMysqlPodTemplate(w *examplev1.Wordpress, deployment *appsv1.Deployment) error {
+	if w.Spec.Mysql == nil {
+		return nil
+	}
+	return patchPodTemplate(deployment, "spec.mysql.podTemplatePatch", w.Spec.Mysql.PodTemplatePatch)
+}
+
+// applies spec.wordpress.podTemplatePatch to the wordpress deployment
+func patchWordpressPodTemplate(w *examplev1.Wordpress, deployment *appsv1.Deployment) error {
+	if w.Spec.Wordpress == nil {
+		return nil
+	}
+	return patchPodTemplate(deployment, "spec.wordpress.podTemplatePatch", w.Spec.Wordpress.PodTemplatePatch)
+}
@@ -0,0 +1,107 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPatchPodTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr string
+	}{
+		{name: "no patch"},
+		{name: "labels added", patch: `{"metadata":{"labels":{"team":"web"}}}`},
+		{
+			name:    "labels replaced",
+			patch:   `{"metadata":{"labels":{"$patch":"replace","team":"web"}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not change the labels app=wordpress,tier=frontend",
+		},
+		{
+			name:    "tier label removed",
+			patch:   `{"metadata":{"labels":{"tier":null}}}`,
+			wantErr: "spec.wordpress.podTemplatePatch must not change the labels app=wordpress,tier=frontend",
+		},
+		{
+			name:    "unknown field",
+			patch:   `{"spec":{"containerz":[]}}`,
+			wantErr: `spec.wordpress.podTemplatePatch: invalid pod template patch: json: unknown field "containerz"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: WordpressLabels()},
+					Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: WordpressLabels()}},
+				},
+			}
+			var patch *runtime.RawExtension
+			if tt.patch != "" {
+				patch = &runtime.RawExtension{Raw: []byte(tt.patch)}
+			}
+
+			err := patchPodTemplate(deployment, "spec.wordpress.podTemplatePatch", patch)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("patchPodTemplate() error = %v, want nil", err)
+				}
+				return
+			}
+			if _, ok := err.(podTemplatePatchError); !ok || err.Error() != tt.wantErr {
+				t.Errorf("patchPodTemplate() error = %#v, want podTemplatePatchError %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReportPodTemplatePatchError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantReady bool
+	}{
+		{name: "other error", err: fmt.Errorf("connection refused")},
+		{name: "patch error", err: podTemplatePatchError{message: "spec.mysql.podTemplatePatch: invalid pod template patch"}, wantReady: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconcileWordpress(t)
+			r.logger = log
+			w := &examplev1.Wordpress{ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"}}
+			r.client = fake.NewClientBuilder().WithScheme(r.scheme).WithObjects(w).Build()
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "production", Name: "mysite"}, w); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.reportPodTemplatePatchError(w, tt.err); err != tt.err {
+				t.Errorf("reportPodTemplatePatchError() = %v, want %v", err, tt.err)
+			}
+
+			stored := &examplev1.Wordpress{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "production", Name: "mysite"}, stored); err != nil {
+				t.Fatal(err)
+			}
+			cond := stored.Status.Conditions.GetCondition(readyCondition)
+			if found := cond != nil; found != tt.wantReady {
+				t.Fatalf("Ready condition = %+v, want found %v", cond, tt.wantReady)
+			}
+			if tt.wantReady && (cond.Status != corev1.ConditionFalse || cond.Reason != "InvalidPodTemplatePatch" || cond.Message != tt.err.Error()) {
+				t.Errorf("Ready condition = %+v, want False InvalidPodTemplatePatch %q", cond, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package wordpress
+
+import (
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// static file checking apache, served without php or the database
+	wordpressLivenessPath = "/license.txt"
+
+	// path loading wordpress, fails while the database is unreachable
+	wordpressReadinessPath = "/wp-login.php"
+)
+
+/////////////////////////////////////////////////////////////////////
+// Container Probes
+/////////////////////////////////////////////////////////////////////
+
+// returns a probe running mysqladmin ping, which succeeds as soon as the
+// server accepts TCP connections, even without credentials
+func mysqladminPingProbe() *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"mysqladmin", "ping", "-h", "127.0.0.1", "--silent"},
+			},
+		},
+		TimeoutSeconds: 5,
+	}
+}
+
+// returns an HTTP GET probe against the wordpress container port
+func wordpressHTTPProbe(path string) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: path,
+				Port: intstr.FromString("wordpress"),
+			},
+		},
+		TimeoutSeconds: 5,
+	}
+}
+
+// sets the default or overridden probes of the mysql container
+func setMysqlProbes(w *examplev1.Wordpress, container *corev1.Container) {
+	// first start initializes the database, allow 5 minutes
+	container.StartupProbe = mysqladminPingProbe()
+	container.StartupProbe.PeriodSeconds = 10
+	container.StartupProbe.FailureThreshold = 30
+
+	container.LivenessProbe = mysqladminPingProbe()
+	container.LivenessProbe.PeriodSeconds = 10
+	container.LivenessProbe.FailureThreshold = 3
+
+	container.ReadinessProbe = mysqladminPingProbe()
+	container.ReadinessProbe.PeriodSeconds = 5
+	container.ReadinessProbe.FailureThreshold = 2
+
+	if spec := w.Spec.Mysql; spec != nil {
+		overrideProbes(container, spec.LivenessProbe, spec.ReadinessProbe, spec.StartupProbe)
+	}
+}
+
+// sets the default or overridden probes of the wordpress container
+func setWordpressProbes(w *examplev1.Wordpress, container *corev1.Container) {
+	// first start copies wordpress to the volume, allow 5 minutes
+	container.StartupProbe = wordpressHTTPProbe(wordpressLivenessPath)
+	container.StartupProbe.PeriodSeconds = 5
+	container.StartupProbe.FailureThreshold = 60
+
+	container.LivenessProbe = wordpressHTTPProbe(wordpressLivenessPath)
+	container.LivenessProbe.PeriodSeconds = 10
+	container.LivenessProbe.FailureThreshold = 3
+
+	container.ReadinessProbe = wordpressHTTPProbe(wordpressReadinessPath)
+	container.ReadinessProbe.PeriodSeconds = 5
+	container.ReadinessProbe.FailureThreshold = 3
+
+	if spec := w.Spec.Wordpress; spec != nil {
+		overrideProbes(container, spec.LivenessProbe, spec.ReadinessProbe, spec.StartupProbe)
+	}
+}
+
+// replaces the probes of container by the probes set in the spec
+func overrideProbes(container *corev1.Container, liveness, readiness, startup *corev1.Probe) {
+	if liveness != nil {
+		container.LivenessProbe = liveness.DeepCopy()
+	}
+	if readiness != nil {
+		container.ReadinessProbe = readiness.DeepCopy()
+	}
+	if startup != nil {
+		container.StartupProbe = startup.DeepCopy()
+	}
+}
@@ -0,0 +1,129 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSetProbes(t *testing.T) {
+	probe := func(base *corev1.Probe, period, failures int32) *corev1.Probe {
+		base.PeriodSeconds = period
+		base.FailureThreshold = failures
+		return base
+	}
+	tcp := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(3306)},
+		},
+		PeriodSeconds: 20,
+	}
+	healthz := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("wordpress")},
+		},
+	}
+
+	mysqlLiveness := probe(mysqladminPingProbe(), 10, 3)
+	mysqlReadiness := probe(mysqladminPingProbe(), 5, 2)
+	mysqlStartup := probe(mysqladminPingProbe(), 10, 30)
+	wordpressLiveness := probe(wordpressHTTPProbe("/license.txt"), 10, 3)
+	wordpressReadiness := probe(wordpressHTTPProbe("/wp-login.php"), 5, 3)
+	wordpressStartup := probe(wordpressHTTPProbe("/license.txt"), 5, 60)
+
+	tests := []struct {
+		name          string
+		spec          examplev1.WordpressSpec
+		set           func(*examplev1.Wordpress, *corev1.Container)
+		wantLiveness  *corev1.Probe
+		wantReadiness *corev1.Probe
+		wantStartup   *corev1.Probe
+	}{
+		{
+			name:          "mysql defaults",
+			set:           setMysqlProbes,
+			wantLiveness:  mysqlLiveness,
+			wantReadiness: mysqlReadiness,
+			wantStartup:   mysqlStartup,
+		},
+		{
+			name:          "mysql liveness override",
+			spec:          examplev1.WordpressSpec{Mysql: &examplev1.WordpressMysqlSpec{LivenessProbe: tcp}},
+			set:           setMysqlProbes,
+			wantLiveness:  tcp,
+			wantReadiness: mysqlReadiness,
+			wantStartup:   mysqlStartup,
+		},
+		{
+			name: "mysql all overridden",
+			spec: examplev1.WordpressSpec{Mysql: &examplev1.WordpressMysqlSpec{
+				LivenessProbe: tcp, ReadinessProbe: tcp, StartupProbe: tcp,
+			}},
+			set:           setMysqlProbes,
+			wantLiveness:  tcp,
+			wantReadiness: tcp,
+			wantStartup:   tcp,
+		},
+		{
+			name:          "wordpress defaults",
+			set:           setWordpressProbes,
+			wantLiveness:  wordpressLiveness,
+			wantReadiness: wordpressReadiness,
+			wantStartup:   wordpressStartup,
+		},
+		{
+			name:          "wordpress spec without probes",
+			spec:          examplev1.WordpressSpec{Wordpress: &examplev1.WordpressFrontendSpec{}},
+			set:           setWordpressProbes,
+			wantLiveness:  wordpressLiveness,
+			wantReadiness: wordpressReadiness,
+			wantStartup:   wordpressStartup,
+		},
+		{
+			name:          "wordpress readiness and startup overrides",
+			spec:          examplev1.WordpressSpec{Wordpress: &examplev1.WordpressFrontendSpec{ReadinessProbe: healthz, StartupProbe: healthz}},
+			set:           setWordpressProbes,
+			wantLiveness:  wordpressLiveness,
+			wantReadiness: healthz,
+			wantStartup:   healthz,
+		},
+		{
+			// the probes of the other tier do not apply
+			name:          "mysql overrides leave wordpress alone",
+			spec:          examplev1.WordpressSpec{Mysql: &examplev1.WordpressMysqlSpec{LivenessProbe: tcp}},
+			set:           setWordpressProbes,
+			wantLiveness:  wordpressLiveness,
+			wantReadiness: wordpressReadiness,
+			wantStartup:   wordpressStartup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{Spec: tt.spec}
+			container := &corev1.Container{}
+			tt.set(w, container)
+
+			if !reflect.DeepEqual(container.LivenessProbe, tt.wantLiveness) {
+				t.Errorf("livenessProbe = %+v, want %+v", container.LivenessProbe, tt.wantLiveness)
+			}
+			if !reflect.DeepEqual(container.ReadinessProbe, tt.wantReadiness) {
+				t.Errorf("readinessProbe = %+v, want %+v", container.ReadinessProbe, tt.wantReadiness)
+			}
+			if !reflect.DeepEqual(container.StartupProbe, tt.wantStartup) {
+				t.Errorf("startupProbe = %+v, want %+v", container.StartupProbe, tt.wantStartup)
+			}
+
+			// overrides are copied, so the pod template does not alias the spec
+			for _, p := range []*corev1.Probe{tcp, healthz} {
+				if container.LivenessProbe == p || container.ReadinessProbe == p || container.StartupProbe == p {
+					t.Errorf("probe %+v is not copied from the spec", p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package wordpress
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// registry of images without a registry host
+	dockerHubRegistry = "docker.io"
+
+	// API host of docker hub
+	dockerHubAPIHost = "registry-1.docker.io"
+
+	// token service of docker hub
+	dockerHubAuthHost = "auth.docker.io"
+)
+
+// manifest media types accepted when resolving a digest, multi-arch indexes first
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// parameters of a WWW-Authenticate challenge
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+var registryClient = &http.Client{Timeout: 30 * time.Second}
+
+// registry credentials from an image pull secret
+type registryAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+/////////////////////////////////////////////////////////////////////
+// Image Digests
+/////////////////////////////////////////////////////////////////////
+
+// returns the registry, repository and tag or digest of an image reference,
+// completed like docker does for docker hub images
+func parseImageReference(image string) (string, string, string) {
+	reference := "latest"
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, reference = image[:i], image[i+1:]
+	} else if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, reference = image[:i], image[i+1:]
+	}
+
+	registry := dockerHubRegistry
+	repository := image
+	if i := strings.Index(image, "/"); i >= 0 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			registry, repository = host, image[i+1:]
+		}
+	}
+	if registry == "index.docker.io" {
+		registry = dockerHubRegistry
+	}
+	if registry == dockerHubRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return registry, repository, reference
+}
+
+// returns the registry a key of a docker config refers to, e.g.
+// https://index.docker.io/v1/ refers to docker.io
+func dockerConfigRegistry(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	if i := strings.Index(key, "/"); i >= 0 {
+		key = key[:i]
+	}
+	if key == "index.docker.io" || key == dockerHubAPIHost {
+		return dockerHubRegistry
+	}
+	return key
+}
+
+// returns the API host of a registry
+func registryHost(registry string) string {
+	if registry == dockerHubRegistry {
+		return dockerHubAPIHost
+	}
+	return registry
+}
+
+// returns true if the credentials for the registry at host may be sent to
+// the token service at realm: the registry itself, docker hub's token
+// service, or a host of WORDPRESS_REGISTRY_AUTH_HOSTS, over https
+func registryAuthHostAllowed(host string, realm *url.URL) bool {
+	if realm.Scheme != "https" {
+		return false
+	}
+	if realm.Host == host || (host == dockerHubAPIHost && realm.Host == dockerHubAuthHost) {
+		return true
+	}
+	for _, allowed := range strings.Split(os.Getenv("WORDPRESS_REGISTRY_AUTH_HOSTS"), ",") {
+		if allowed = strings.TrimSpace(allowed); allowed != "" && allowed == realm.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the parameters of a WWW-Authenticate challenge
+func parseChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	return params
+}
+
+// returns the credentials for registry from the image pull secrets of w
+func (r *ReconcileWordpress) registryCredentials(w *examplev1.Wordpress, registry string) (*registryAuth, error) {
+	for _, pullSecret := range imagePullSecrets(w) {
+		secret := &corev1.Secret{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: pullSecret.Name}, secret)
+		if err != nil {
+			return nil, fmt.Errorf("image pull secret %s: %v", pullSecret.Name, err)
+		}
+
+		auths := map[string]registryAuth{}
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			config := struct {
+				Auths map[string]registryAuth `json:"auths"`
+			}{}
+			err = json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config)
+			auths = config.Auths
+		case corev1.SecretTypeDockercfg:
+			err = json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("image pull secret %s: %v", pullSecret.Name, err)
+		}
+
+		for key, auth := range auths {
+			if dockerConfigRegistry(key) != registry {
+				continue
+			}
+			if auth.Auth != "" {
+				decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+				if err != nil {
+					return nil, fmt.Errorf("image pull secret %s: %v", pullSecret.Name, err)
+				}
+				credentials := strings.SplitN(string(decoded), ":", 2)
+				if len(credentials) == 2 {
+					auth.Username, auth.Password = credentials[0], credentials[1]
+				}
+			}
+			return &auth, nil
+		}
+	}
+	return nil, nil
+}
+
+// returns the Authorization header answering a WWW-Authenticate challenge of
+// the registry at host, requesting a bearer token from its token service
+func registryAuthorization(challenge string, host string, repository string, auth *registryAuth) (string, error) {
+	params := parseChallenge(challenge)
+
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") {
+		if auth == nil {
+			return "", fmt.Errorf("registry requires credentials, add an image pull secret")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password)), nil
+	}
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer") || params["realm"] == "" {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", repository)
+	}
+	query.Set("scope", scope)
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", fmt.Errorf("invalid registry token service %q: %v", params["realm"], err)
+	}
+	if auth != nil && !registryAuthHostAllowed(host, realm) {
+		return "", fmt.Errorf("not sending the credentials for %s to the token service %s, add its host to WORDPRESS_REGISTRY_AUTH_HOSTS",
+			host, params["realm"])
+	}
+	realm.RawQuery = query.Encode()
+
+	request, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if auth != nil {
+		request.SetBasicAuth(auth.Username, auth.Password)
+	}
+	response, err := registryClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token service returned %s", response.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// requests the manifest of an image from the registry at host, answering an
+// authentication challenge
+func requestManifest(method string, host string, manifestURL string, repository string, auth *registryAuth) (*http.Response, error) {
+	authorization := ""
+	for attempt := 0; attempt < 2; attempt++ {
+		request, err := http.NewRequest(method, manifestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+
+		response, err := registryClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusUnauthorized || authorization != "" {
+			return response, nil
+		}
+		response.Body.Close()
+
+		authorization, err = registryAuthorization(response.Header.Get("WWW-Authenticate"), host, repository, auth)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("registry rejected the credentials")
+}
+
+// returns the digest the registry resolves the tag of image to, with the
+// credentials of the image pull secrets of w
+func (r *ReconcileWordpress) resolveImageDigest(w *examplev1.Wordpress, image string) (string, error) {
+	registry, repository, reference := parseImageReference(image)
+	if strings.HasPrefix(reference, "sha256:") {
+		return reference, nil
+	}
+
+	auth, err := r.registryCredentials(w, registry)
+	if err != nil {
+		return "", err
+	}
+	host := registryHost(registry)
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repository, reference)
+
+	response, err := requestManifest(http.MethodHead, host, manifestURL, repository, auth)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %v", image, err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving %s: registry returned %s", image, response.Status)
+	}
+	if digest := response.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// the registry doesn't return the digest on HEAD, hash the manifest
+	response, err = requestManifest(http.MethodGet, host, manifestURL, repository, auth)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %v", image, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving %s: registry returned %s", image, response.Status)
+	}
+	manifest, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %v", image, err)
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(manifest)), nil
+}
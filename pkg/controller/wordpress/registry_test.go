@@ -0,0 +1,206 @@
+package wordpress
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image      string
+		registry   string
+		repository string
+		reference  string
+	}{
+		{image: "wordpress", registry: "docker.io", repository: "library/wordpress", reference: "latest"},
+		{image: "wordpress:4.8-apache", registry: "docker.io", repository: "library/wordpress", reference: "4.8-apache"},
+		{image: "bitnami/wordpress:6", registry: "docker.io", repository: "bitnami/wordpress", reference: "6"},
+		{image: "docker.io/wordpress:6", registry: "docker.io", repository: "library/wordpress", reference: "6"},
+		{image: "index.docker.io/library/mysql:5.6", registry: "docker.io", repository: "library/mysql", reference: "5.6"},
+		{image: "quay.io/example/wordpress:6", registry: "quay.io", repository: "example/wordpress", reference: "6"},
+		{image: "registry.example.com:5000/wordpress", registry: "registry.example.com:5000", repository: "wordpress", reference: "latest"},
+		{image: "localhost/wordpress:dev", registry: "localhost", repository: "wordpress", reference: "dev"},
+		{image: "mysql@sha256:abc", registry: "docker.io", repository: "library/mysql", reference: "sha256:abc"},
+		{image: "quay.io/example/mysql:5.6@sha256:abc", registry: "quay.io", repository: "example/mysql:5.6", reference: "sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			registry, repository, reference := parseImageReference(tt.image)
+			if registry != tt.registry || repository != tt.repository || reference != tt.reference {
+				t.Errorf("parseImageReference() = %q, %q, %q, want %q, %q, %q",
+					registry, repository, reference, tt.registry, tt.repository, tt.reference)
+			}
+		})
+	}
+}
+
+func TestDockerConfigRegistry(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "https://index.docker.io/v1/", want: "docker.io"},
+		{key: "index.docker.io", want: "docker.io"},
+		{key: "registry-1.docker.io", want: "docker.io"},
+		{key: "docker.io", want: "docker.io"},
+		{key: "quay.io", want: "quay.io"},
+		{key: "https://registry.example.com:5000/v2/", want: "registry.example.com:5000"},
+		{key: "http://localhost:5000", want: "localhost:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := dockerConfigRegistry(tt.key); got != tt.want {
+				t.Errorf("dockerConfigRegistry() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		challenge string
+		want      map[string]string
+	}{
+		{
+			challenge: `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/mysql:pull"`,
+			want: map[string]string{
+				"realm":   "https://auth.docker.io/token",
+				"service": "registry.docker.io",
+				"scope":   "repository:library/mysql:pull",
+			},
+		},
+		{challenge: `Basic realm="Registry"`, want: map[string]string{"realm": "Registry"}},
+		{challenge: `Bearer realm=""`, want: map[string]string{"realm": ""}},
+		{challenge: "", want: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.challenge, func(t *testing.T) {
+			if got := parseChallenge(tt.challenge); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseChallenge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryAuthHostAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		host  string
+		realm string
+		env   string
+		want  bool
+	}{
+		{name: "registry host", host: "quay.io", realm: "https://quay.io/v2/auth", want: true},
+		{name: "registry host with port", host: "registry.example.com:5000", realm: "https://registry.example.com:5000/token", want: true},
+		{name: "docker hub", host: dockerHubAPIHost, realm: "https://auth.docker.io/token", want: true},
+		{name: "docker hub token service for another registry", host: "quay.io", realm: "https://auth.docker.io/token"},
+		{name: "other host", host: "quay.io", realm: "https://attacker.example.com/token"},
+		{name: "other port", host: "registry.example.com", realm: "https://registry.example.com:8443/token"},
+		{name: "http", host: "quay.io", realm: "http://quay.io/v2/auth"},
+		{name: "allowed host", host: "registry.example.com", realm: "https://auth.example.com/token", env: "sso.example.com, auth.example.com", want: true},
+		{name: "allowed host over http", host: "registry.example.com", realm: "http://auth.example.com/token", env: "auth.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WORDPRESS_REGISTRY_AUTH_HOSTS", tt.env)
+			realm, err := url.Parse(tt.realm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := registryAuthHostAllowed(tt.host, realm); got != tt.want {
+				t.Errorf("registryAuthHostAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryAuthorization(t *testing.T) {
+	var username, password, scope string
+	var requests int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		username, password, _ = r.BasicAuth()
+		scope = r.URL.Query().Get("scope")
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "token"})
+	}))
+	defer server.Close()
+	client := registryClient
+	registryClient = server.Client()
+	defer func() { registryClient = client }()
+
+	serverHost := strings.TrimPrefix(server.URL, "https://")
+	challenge := `Bearer realm="` + server.URL + `/token",service="registry"`
+	auth := &registryAuth{Username: "user", Password: "secret"}
+
+	tests := []struct {
+		name         string
+		challenge    string
+		host         string
+		env          string
+		auth         *registryAuth
+		want         string
+		wantErr      string
+		wantUsername string
+	}{
+		{name: "token service on the registry host", challenge: challenge, host: serverHost, auth: auth, want: "Bearer token", wantUsername: "user"},
+		{name: "anonymous token", challenge: challenge, host: "registry.example.com", want: "Bearer token"},
+		{
+			name:      "credentials for another host",
+			challenge: challenge,
+			host:      "registry.example.com",
+			auth:      auth,
+			wantErr:   "not sending the credentials for registry.example.com",
+		},
+		{
+			name:         "allowed token service",
+			challenge:    challenge,
+			host:         "registry.example.com",
+			env:          serverHost,
+			auth:         auth,
+			want:         "Bearer token",
+			wantUsername: "user",
+		},
+		{name: "basic", challenge: `Basic realm="Registry"`, host: serverHost, auth: auth, want: "Basic dXNlcjpzZWNyZXQ="},
+		{name: "basic without credentials", challenge: `Basic realm="Registry"`, host: serverHost, wantErr: "registry requires credentials"},
+		{name: "unsupported", challenge: `Negotiate`, host: serverHost, wantErr: "unsupported registry authentication"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WORDPRESS_REGISTRY_AUTH_HOSTS", tt.env)
+			requests, username, password, scope = 0, "", "", ""
+
+			got, err := registryAuthorization(tt.challenge, tt.host, "example/wordpress", tt.auth)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("registryAuthorization() error = %v, want %q", err, tt.wantErr)
+				}
+				if requests != 0 {
+					t.Errorf("registryAuthorization() sent %d requests, want none", requests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("registryAuthorization() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("registryAuthorization() = %q, want %q", got, tt.want)
+			}
+			if username != tt.wantUsername || (tt.wantUsername != "" && password != "secret") {
+				t.Errorf("token service got credentials %q:%q, want user %q", username, password, tt.wantUsername)
+			}
+			if requests > 0 && scope != "repository:example/wordpress:pull" {
+				t.Errorf("scope = %q, want repository:example/wordpress:pull", scope)
+			}
+		})
+	}
+}
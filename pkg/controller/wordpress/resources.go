@@ -0,0 +1,72 @@
+package wordpress
+
+import (
+	"os"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+/////////////////////////////////////////////////////////////////////
+// Container Resources
+/////////////////////////////////////////////////////////////////////
+
+// returns the name of the preset of w, from spec.preset or WORDPRESS_DEFAULT_PRESET
+func presetName(w *examplev1.Wordpress) string {
+	return w.PresetName(os.Getenv("WORDPRESS_DEFAULT_PRESET"))
+}
+
+// returns an error if the preset of w is not defined in WORDPRESS_PRESETS
+func validatePreset(w *examplev1.Wordpress) error {
+	return w.ValidatePreset(os.Getenv("WORDPRESS_PRESETS"), os.Getenv("WORDPRESS_DEFAULT_PRESET"))
+}
+
+// returns the preset of w, or an empty preset if none is configured
+func wordpressPreset(w *examplev1.Wordpress) examplev1.WordpressPreset {
+	presets, err := examplev1.ParsePresets(os.Getenv("WORDPRESS_PRESETS"))
+	if err != nil {
+		return examplev1.WordpressPreset{}
+	}
+	return presets[presetName(w)]
+}
+
+// returns the resources of the preset, overridden by the resources of the spec
+func mergeResources(preset corev1.ResourceRequirements, spec *corev1.ResourceRequirements) corev1.ResourceRequirements {
+	resources := *preset.DeepCopy()
+	if spec == nil {
+		return resources
+	}
+
+	for name, quantity := range spec.Requests {
+		if resources.Requests == nil {
+			resources.Requests = corev1.ResourceList{}
+		}
+		resources.Requests[name] = quantity
+	}
+	for name, quantity := range spec.Limits {
+		if resources.Limits == nil {
+			resources.Limits = corev1.ResourceList{}
+		}
+		resources.Limits[name] = quantity
+	}
+	return resources
+}
+
+// returns the resources of the mysql container
+func mysqlResources(w *examplev1.Wordpress) corev1.ResourceRequirements {
+	var spec *corev1.ResourceRequirements
+	if w.Spec.Mysql != nil {
+		spec = w.Spec.Mysql.Resources
+	}
+	return mergeResources(wordpressPreset(w).Mysql, spec)
+}
+
+// returns the resources of the wordpress container
+func wordpressResources(w *examplev1.Wordpress) corev1.ResourceRequirements {
+	var spec *corev1.ResourceRequirements
+	if w.Spec.Wordpress != nil {
+		spec = w.Spec.Wordpress.Resources
+	}
+	return mergeResources(wordpressPreset(w).Wordpress, spec)
+}
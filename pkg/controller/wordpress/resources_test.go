@@ -0,0 +1,70 @@
+package wordpress
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestMergeResources(t *testing.T) {
+	list := func(cpu, memory string) corev1.ResourceList {
+		l := corev1.ResourceList{}
+		if cpu != "" {
+			l[corev1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if memory != "" {
+			l[corev1.ResourceMemory] = resource.MustParse(memory)
+		}
+		return l
+	}
+	preset := corev1.ResourceRequirements{Requests: list("100m", "128Mi"), Limits: list("", "256Mi")}
+
+	tests := []struct {
+		name   string
+		preset corev1.ResourceRequirements
+		spec   *corev1.ResourceRequirements
+		want   corev1.ResourceRequirements
+	}{
+		{name: "no spec", preset: preset, want: preset},
+		{name: "empty spec", preset: preset, spec: &corev1.ResourceRequirements{}, want: preset},
+		{
+			name:   "overrides a request",
+			preset: preset,
+			spec:   &corev1.ResourceRequirements{Requests: list("500m", "")},
+			want:   corev1.ResourceRequirements{Requests: list("500m", "128Mi"), Limits: list("", "256Mi")},
+		},
+		{
+			name:   "adds a limit",
+			preset: preset,
+			spec:   &corev1.ResourceRequirements{Limits: list("1", "")},
+			want:   corev1.ResourceRequirements{Requests: list("100m", "128Mi"), Limits: list("1", "256Mi")},
+		},
+		{
+			name:   "overrides everything",
+			preset: preset,
+			spec:   &corev1.ResourceRequirements{Requests: list("1", "1Gi"), Limits: list("2", "2Gi")},
+			want:   corev1.ResourceRequirements{Requests: list("1", "1Gi"), Limits: list("2", "2Gi")},
+		},
+		{
+			name: "no preset",
+			spec: &corev1.ResourceRequirements{Requests: list("250m", ""), Limits: list("", "512Mi")},
+			want: corev1.ResourceRequirements{Requests: list("250m", ""), Limits: list("", "512Mi")},
+		},
+		{name: "no preset or spec"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.preset.DeepCopy()
+			got := mergeResources(tt.preset, tt.spec)
+			if !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("mergeResources() = %+v, want %+v", got, tt.want)
+			}
+			if !equality.Semantic.DeepEqual(&tt.preset, original) {
+				t.Errorf("mergeResources() modified the preset: %+v", tt.preset)
+			}
+		})
+	}
+}
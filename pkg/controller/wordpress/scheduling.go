@@ -0,0 +1,67 @@
+package wordpress
+
+import (
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/////////////////////////////////////////////////////////////////////
+// Pod Scheduling
+/////////////////////////////////////////////////////////////////////
+
+// returns a soft anti-affinity spreading the pods matching labels over nodes
+func genPodAntiAffinity(labels map[string]string) *corev1.PodAntiAffinity {
+	return &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+			Weight: 100,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: labels,
+				},
+				TopologyKey: corev1.LabelHostname,
+			},
+		}},
+	}
+}
+
+// sets the node selector, affinity, tolerations, topology spread and
+// priority class of a pod from the spec of its tier
+func setScheduling(pod *corev1.PodSpec, spec *examplev1.WordpressSchedulingSpec) {
+	if spec == nil {
+		return
+	}
+	pod.NodeSelector = spec.NodeSelector
+	pod.Tolerations = spec.Tolerations
+	pod.TopologySpreadConstraints = spec.TopologySpreadConstraints
+	pod.PriorityClassName = spec.PriorityClassName
+
+	if spec.Affinity == nil {
+		return
+	}
+	// keep the default pod anti-affinity unless the spec sets one
+	affinity := spec.Affinity.DeepCopy()
+	if affinity.PodAntiAffinity == nil && pod.Affinity != nil {
+		affinity.PodAntiAffinity = pod.Affinity.PodAntiAffinity
+	}
+	pod.Affinity = affinity
+}
+
+// sets the scheduling of the mysql pod
+func setMysqlScheduling(w *examplev1.Wordpress, pod *corev1.PodSpec) {
+	if w.Spec.Mysql != nil {
+		setScheduling(pod, &w.Spec.Mysql.WordpressSchedulingSpec)
+	}
+}
+
+// sets the scheduling of the wordpress pods, preferring separate nodes for
+// the replicas of the site
+func setWordpressScheduling(w *examplev1.Wordpress, pod *corev1.PodSpec) {
+	pod.Affinity = &corev1.Affinity{
+		PodAntiAffinity: genPodAntiAffinity(WordpressLabels()),
+	}
+	if w.Spec.Wordpress != nil {
+		setScheduling(pod, &w.Spec.Wordpress.WordpressSchedulingSpec)
+	}
+}
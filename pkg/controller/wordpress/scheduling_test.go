@@ -0,0 +1,118 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSetScheduling(t *testing.T) {
+	nodeAffinity := &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      "node.kubernetes.io/instance-type",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{"m5.large"},
+				}},
+			}},
+		},
+	}
+	antiAffinity := &corev1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{TopologyKey: corev1.LabelTopologyZone}},
+	}
+	scheduling := examplev1.WordpressSchedulingSpec{
+		NodeSelector: map[string]string{"disktype": "ssd"},
+		Tolerations: []corev1.Toleration{{
+			Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "wordpress", Effect: corev1.TaintEffectNoSchedule,
+		}},
+		TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{
+			MaxSkew: 1, TopologyKey: corev1.LabelTopologyZone, WhenUnsatisfiable: corev1.ScheduleAnyway,
+		}},
+		PriorityClassName: "high-priority",
+	}
+	withAffinity := func(affinity *corev1.Affinity) examplev1.WordpressSchedulingSpec {
+		spec := scheduling
+		spec.Affinity = affinity
+		return spec
+	}
+	defaultAffinity := &corev1.Affinity{PodAntiAffinity: genPodAntiAffinity(WordpressLabels())}
+
+	tests := []struct {
+		name         string
+		spec         examplev1.WordpressSpec
+		set          func(*examplev1.Wordpress, *corev1.PodSpec)
+		wantAffinity *corev1.Affinity
+		wantSpec     *examplev1.WordpressSchedulingSpec
+	}{
+		{name: "mysql defaults", set: setMysqlScheduling},
+		{
+			name:     "mysql scheduling",
+			spec:     examplev1.WordpressSpec{Mysql: &examplev1.WordpressMysqlSpec{WordpressSchedulingSpec: withAffinity(&corev1.Affinity{NodeAffinity: nodeAffinity})}},
+			set:      setMysqlScheduling,
+			wantSpec: &scheduling,
+			// mysql has no default pod anti-affinity to keep
+			wantAffinity: &corev1.Affinity{NodeAffinity: nodeAffinity},
+		},
+		{
+			name:         "wordpress defaults",
+			set:          setWordpressScheduling,
+			wantAffinity: defaultAffinity,
+		},
+		{
+			name:         "wordpress spec without scheduling",
+			spec:         examplev1.WordpressSpec{Wordpress: &examplev1.WordpressFrontendSpec{}},
+			set:          setWordpressScheduling,
+			wantAffinity: defaultAffinity,
+		},
+		{
+			name:         "wordpress scheduling keeps the default anti-affinity",
+			spec:         examplev1.WordpressSpec{Wordpress: &examplev1.WordpressFrontendSpec{WordpressSchedulingSpec: withAffinity(&corev1.Affinity{NodeAffinity: nodeAffinity})}},
+			set:          setWordpressScheduling,
+			wantSpec:     &scheduling,
+			wantAffinity: &corev1.Affinity{NodeAffinity: nodeAffinity, PodAntiAffinity: defaultAffinity.PodAntiAffinity},
+		},
+		{
+			name:         "wordpress anti-affinity replaces the default",
+			spec:         examplev1.WordpressSpec{Wordpress: &examplev1.WordpressFrontendSpec{WordpressSchedulingSpec: withAffinity(&corev1.Affinity{PodAntiAffinity: antiAffinity})}},
+			set:          setWordpressScheduling,
+			wantSpec:     &scheduling,
+			wantAffinity: &corev1.Affinity{PodAntiAffinity: antiAffinity},
+		},
+		{
+			// the scheduling of the other tier does not apply
+			name:         "mysql scheduling leaves wordpress alone",
+			spec:         examplev1.WordpressSpec{Mysql: &examplev1.WordpressMysqlSpec{WordpressSchedulingSpec: scheduling}},
+			set:          setWordpressScheduling,
+			wantAffinity: defaultAffinity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{Spec: tt.spec}
+			pod := &corev1.PodSpec{}
+			tt.set(w, pod)
+
+			want := examplev1.WordpressSchedulingSpec{}
+			if tt.wantSpec != nil {
+				want = *tt.wantSpec
+			}
+			got := examplev1.WordpressSchedulingSpec{
+				NodeSelector:              pod.NodeSelector,
+				Tolerations:               pod.Tolerations,
+				TopologySpreadConstraints: pod.TopologySpreadConstraints,
+				PriorityClassName:         pod.PriorityClassName,
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("scheduling = %+v, want %+v", got, want)
+			}
+			if !reflect.DeepEqual(pod.Affinity, tt.wantAffinity) {
+				t.Errorf("affinity = %+v, want %+v", pod.Affinity, tt.wantAffinity)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package wordpress
+
+import (
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// uid and gid of the mysql user of the mysql image
+	mysqlUID = 999
+)
+
+// capabilities apache needs to bind port 80, prepare the document root and
+// switch its workers to www-data
+var wordpressCapabilities = []corev1.Capability{
+	"CHOWN", "DAC_OVERRIDE", "FOWNER", "KILL", "NET_BIND_SERVICE", "SETGID", "SETUID",
+}
+
+/////////////////////////////////////////////////////////////////////
+// Pod Security Contexts
+/////////////////////////////////////////////////////////////////////
+
+// mounts writable emptyDirs in a container with a read-only root filesystem
+func addWritableDirs(pod *corev1.PodSpec, container *corev1.Container, mounts []corev1.VolumeMount) {
+	for _, mount := range mounts {
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: mount.Name,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+	}
+}
+
+// returns the seccomp profile of the container runtime
+func seccompRuntimeDefault() *corev1.SeccompProfile {
+	return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+}
+
+// sets the default or overridden security contexts of the mysql pod, which
+// meet the restricted Pod Security Standard
+func setMysqlSecurityContext(w *examplev1.Wordpress, deployment *appsv1.Deployment) {
+	pod := &deployment.Spec.Template.Spec
+	container := &pod.Containers[0]
+	uid := int64(mysqlUID)
+	nonRoot := true
+	escalation := false
+	readOnly := true
+
+	pod.SecurityContext = &corev1.PodSecurityContext{
+		RunAsUser:    &uid,
+		RunAsGroup:   &uid,
+		RunAsNonRoot: &nonRoot,
+		// make the mysql PVC writable by the mysql user
+		FSGroup:        &uid,
+		SeccompProfile: seccompRuntimeDefault(),
+	}
+	container.SecurityContext = &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &escalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+		ReadOnlyRootFilesystem: &readOnly,
+	}
+	addWritableDirs(pod, container, []corev1.VolumeMount{
+		{Name: "mysql-run", MountPath: "/var/run/mysqld"},
+		{Name: "mysql-tmp", MountPath: "/tmp"},
+	})
+
+	if spec := w.Spec.Mysql; spec != nil {
+		overrideSecurityContexts(pod, container, spec.PodSecurityContext, spec.SecurityContext)
+	}
+}
+
+// sets the default or overridden security contexts of the wordpress pods,
+// which meet the baseline Pod Security Standard: apache starts as root
+func setWordpressSecurityContext(w *examplev1.Wordpress, deployment *appsv1.Deployment) {
+	pod := &deployment.Spec.Template.Spec
+	container := &pod.Containers[0]
+	escalation := false
+	readOnly := true
+
+	pod.SecurityContext = &corev1.PodSecurityContext{
+		SeccompProfile: seccompRuntimeDefault(),
+	}
+	container.SecurityContext = &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &escalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+			Add:  wordpressCapabilities,
+		},
+		ReadOnlyRootFilesystem: &readOnly,
+	}
+	addWritableDirs(pod, container, []corev1.VolumeMount{
+		{Name: "wordpress-run", MountPath: "/var/run/apache2"},
+		{Name: "wordpress-lock", MountPath: "/var/lock/apache2"},
+		{Name: "wordpress-tmp", MountPath: "/tmp"},
+	})
+
+	if spec := w.Spec.Wordpress; spec != nil {
+		overrideSecurityContexts(pod, container, spec.PodSecurityContext, spec.SecurityContext)
+	}
+}
+
+// replaces the security contexts of the pod and container by the ones set in the spec
+func overrideSecurityContexts(pod *corev1.PodSpec, container *corev1.Container,
+	podSecurityContext *corev1.PodSecurityContext, securityContext *corev1.SecurityContext) {
+	if podSecurityContext != nil {
+		pod.SecurityContext = podSecurityContext.DeepCopy()
+	}
+	if securityContext != nil {
+		container.SecurityContext = securityContext.DeepCopy()
+	}
+}
@@ -0,0 +1,96 @@
+package wordpress
+
+import (
+	"context"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// name of the ServiceAccount the mysql and wordpress pods run as
+const serviceAccountName = "wordpress"
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile ServiceAccount
+/////////////////////////////////////////////////////////////////////
+
+// returns true if the ServiceAccount token is mounted in the pods
+func automountServiceAccountToken(w *examplev1.Wordpress) bool {
+	return w.Spec.ServiceAccount != nil && w.Spec.ServiceAccount.AutomountServiceAccountToken
+}
+
+// returns the ServiceAccount of the mysql and wordpress pods
+func (r *ReconcileWordpress) genServiceAccount(w *examplev1.Wordpress) *corev1.ServiceAccount {
+	automount := automountServiceAccountToken(w)
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceAccountName,
+			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		AutomountServiceAccountToken: &automount,
+	}
+	serviceAccount.Annotations = serviceAccountAnnotations(nil, w)
+
+	controllerutil.SetControllerReference(w, serviceAccount, r.scheme)
+	return serviceAccount
+}
+
+// returns the annotations of spec.serviceAccount merged into existing, only
+// replacing the annotations previously set by the operator, so annotations
+// added by others, e.g. by a workload identity webhook, are kept
+func serviceAccountAnnotations(existing map[string]string, w *examplev1.Wordpress) map[string]string {
+	var managed map[string]string
+	if w.Spec.ServiceAccount != nil {
+		managed = w.Spec.ServiceAccount.Annotations
+	}
+
+	annotations := map[string]string{}
+	for key, value := range existing {
+		annotations[key] = value
+	}
+	mergeManagedAnnotations(annotations, managed)
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// creates the ServiceAccount, or updates its annotations
+func (r *ReconcileWordpress) reconcileServiceAccount(w *examplev1.Wordpress) error {
+	serviceAccount := r.genServiceAccount(w)
+
+	existing := &corev1.ServiceAccount{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: serviceAccount.Namespace, Name: serviceAccount.Name}, existing)
+	if errors.IsNotFound(err) {
+		return r.CreateObject(serviceAccount, "ServiceAccount")
+	} else if err != nil {
+		return err
+	}
+
+	// leave a ServiceAccount created by someone else as is
+	if !metav1.IsControlledBy(existing, w) {
+		return nil
+	}
+	annotations := serviceAccountAnnotations(existing.Annotations, w)
+	if equality.Semantic.DeepEqual(existing.Annotations, annotations) &&
+		equality.Semantic.DeepEqual(existing.AutomountServiceAccountToken, serviceAccount.AutomountServiceAccountToken) {
+		return nil
+	}
+	existing.Annotations = annotations
+	existing.AutomountServiceAccountToken = serviceAccount.AutomountServiceAccountToken
+	return r.UpdateObject(existing, "ServiceAccount")
+}
+
+// runs a pod as the ServiceAccount, without its token unless requested
+func setServiceAccount(w *examplev1.Wordpress, pod *corev1.PodSpec) {
+	automount := automountServiceAccountToken(w)
+	pod.ServiceAccountName = serviceAccountName
+	pod.AutomountServiceAccountToken = &automount
+}
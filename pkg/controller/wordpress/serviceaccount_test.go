@@ -0,0 +1,173 @@
+package wordpress
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGenServiceAccount(t *testing.T) {
+	tests := []struct {
+		name            string
+		serviceAccount  *examplev1.WordpressServiceAccountSpec
+		wantAnnotations map[string]string
+		wantAutomount   bool
+	}{
+		{name: "defaults"},
+		{name: "empty spec", serviceAccount: &examplev1.WordpressServiceAccountSpec{}},
+		{
+			name: "annotations and token",
+			serviceAccount: &examplev1.WordpressServiceAccountSpec{
+				Annotations:                  map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/wordpress"},
+				AutomountServiceAccountToken: true,
+			},
+			wantAnnotations: map[string]string{
+				"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/wordpress",
+				managedAnnotationsKey:        "eks.amazonaws.com/role-arn",
+			},
+			wantAutomount: true,
+		},
+	}
+
+	r := testReconcileWordpress(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+				Spec:       examplev1.WordpressSpec{ServiceAccount: tt.serviceAccount},
+			}
+
+			serviceAccount := r.genServiceAccount(w)
+			if serviceAccount.Name != serviceAccountName || serviceAccount.Namespace != "production" {
+				t.Errorf("serviceAccount = %s/%s, want production/%s", serviceAccount.Namespace, serviceAccount.Name, serviceAccountName)
+			}
+			if !reflect.DeepEqual(serviceAccount.Annotations, tt.wantAnnotations) {
+				t.Errorf("annotations = %v, want %v", serviceAccount.Annotations, tt.wantAnnotations)
+			}
+			if *serviceAccount.AutomountServiceAccountToken != tt.wantAutomount {
+				t.Errorf("automountServiceAccountToken = %v, want %v", *serviceAccount.AutomountServiceAccountToken, tt.wantAutomount)
+			}
+
+			pod := &corev1.PodSpec{}
+			setServiceAccount(w, pod)
+			if pod.ServiceAccountName != serviceAccountName || *pod.AutomountServiceAccountToken != tt.wantAutomount {
+				t.Errorf("pod = %s automount %v, want %s automount %v", pod.ServiceAccountName, *pod.AutomountServiceAccountToken, serviceAccountName, tt.wantAutomount)
+			}
+		})
+	}
+}
+
+func TestServiceAccountAnnotations(t *testing.T) {
+	role := "eks.amazonaws.com/role-arn"
+	gcp := "iam.gke.io/gcp-service-account"
+	foreign := "example.com/owner"
+
+	tests := []struct {
+		name     string
+		existing map[string]string
+		managed  map[string]string
+		want     map[string]string
+	}{
+		{name: "none"},
+		{
+			name:    "added",
+			managed: map[string]string{role: "arn:a"},
+			want:    map[string]string{role: "arn:a", managedAnnotationsKey: role},
+		},
+		{
+			name:     "foreign annotations are kept",
+			existing: map[string]string{foreign: "web-team"},
+			managed:  map[string]string{role: "arn:a"},
+			want:     map[string]string{foreign: "web-team", role: "arn:a", managedAnnotationsKey: role},
+		},
+		{
+			name:     "changed",
+			existing: map[string]string{foreign: "web-team", role: "arn:a", managedAnnotationsKey: role},
+			managed:  map[string]string{gcp: "wordpress@project.iam.gserviceaccount.com"},
+			want:     map[string]string{foreign: "web-team", gcp: "wordpress@project.iam.gserviceaccount.com", managedAnnotationsKey: gcp},
+		},
+		{
+			name:     "removed from the spec",
+			existing: map[string]string{foreign: "web-team", role: "arn:a", managedAnnotationsKey: role},
+			want:     map[string]string{foreign: "web-team"},
+		},
+		{
+			name:     "all removed",
+			existing: map[string]string{role: "arn:a", managedAnnotationsKey: role},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &examplev1.Wordpress{}
+			if tt.managed != nil {
+				w.Spec.ServiceAccount = &examplev1.WordpressServiceAccountSpec{Annotations: tt.managed}
+			}
+			var existing map[string]string
+			if tt.existing != nil {
+				existing = map[string]string{}
+				for key, value := range tt.existing {
+					existing[key] = value
+				}
+			}
+
+			got := serviceAccountAnnotations(existing, w)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("serviceAccountAnnotations() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(existing, tt.existing) {
+				t.Errorf("existing annotations modified to %v", existing)
+			}
+		})
+	}
+}
+
+func TestReconcileServiceAccount(t *testing.T) {
+	r := testReconcileWordpress(t)
+	if err := clientgoscheme.AddToScheme(r.scheme); err != nil {
+		t.Fatal(err)
+	}
+	r.logger = log
+	w := &examplev1.Wordpress{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production", UID: "1"},
+		Spec: examplev1.WordpressSpec{ServiceAccount: &examplev1.WordpressServiceAccountSpec{
+			Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:a"},
+		}},
+	}
+	r.client = fake.NewClientBuilder().WithScheme(r.scheme).Build()
+	key := types.NamespacedName{Namespace: "production", Name: serviceAccountName}
+
+	if err := r.reconcileServiceAccount(w); err != nil {
+		t.Fatalf("reconcileServiceAccount() error = %v", err)
+	}
+
+	// annotations added by others survive the updates of the operator
+	serviceAccount := &corev1.ServiceAccount{}
+	if err := r.client.Get(context.TODO(), key, serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+	serviceAccount.Annotations["example.com/owner"] = "web-team"
+	if err := r.client.Update(context.TODO(), serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Spec.ServiceAccount = nil
+	if err := r.reconcileServiceAccount(w); err != nil {
+		t.Fatalf("reconcileServiceAccount() error = %v", err)
+	}
+	if err := r.client.Get(context.TODO(), key, serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"example.com/owner": "web-team"}
+	if !reflect.DeepEqual(serviceAccount.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", serviceAccount.Annotations, want)
+	}
+}
@@ -0,0 +1,259 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	condv1 "github.com/operator-framework/operator-sdk/pkg/status"
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// condition aggregating the readiness of the mysql and wordpress Deployments
+const readyCondition condv1.ConditionType = "Ready"
+
+/////////////////////////////////////////////////////////////////////
+// Wordpress URL and Ready Status
+/////////////////////////////////////////////////////////////////////
+
+// returns the URLs the site is reachable at, most preferred first: the
+// Ingress or Gateway hostnames, the load balancer, a node port on a node
+// running wordpress and the cluster internal Service name
+func (r *ReconcileWordpress) genEndpoints(w *examplev1.Wordpress) ([]string, error) {
+	endpoints := []string{}
+
+	scheme := "http"
+	if wordpressTLSSecretName(w) != "" {
+		scheme = "https"
+	}
+	for _, hostname := range wordpressHostnames(w) {
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s", scheme, hostname))
+	}
+
+	service := &corev1.Service{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: "wordpress"}, service)
+	if errors.IsNotFound(err) {
+		return endpoints, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(service.Spec.Ports) == 0 {
+		return endpoints, nil
+	}
+	port := service.Spec.Ports[0]
+
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			address := ingress.Hostname
+			if ingress.IP != "" {
+				address = ingress.IP
+			}
+			endpoints = append(endpoints, httpURL(address, port.Port))
+		}
+	}
+
+	if port.NodePort != 0 {
+		pods := &corev1.PodList{}
+		err = r.client.List(context.TODO(), pods, client.InNamespace(w.Namespace),
+			client.MatchingLabels(WordpressLabels()))
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodRunning && pod.Status.HostIP != "" {
+				endpoints = append(endpoints, httpURL(pod.Status.HostIP, port.NodePort))
+				break
+			}
+		}
+	}
+
+	endpoints = append(endpoints, httpURL(fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace), port.Port))
+	return endpoints, nil
+}
+
+// returns an http URL, omitting the default port
+func httpURL(host string, port int32) string {
+	if port == 80 {
+		return fmt.Sprintf("http://%s", host)
+	}
+	return fmt.Sprintf("http://%s:%d", host, port)
+}
+
+// returns the Ready condition from the availability of all desired replicas
+// of the mysql and wordpress Deployments and the probes of their pods, the tag of the
+// wordpress image and the number of wordpress pods
+func (r *ReconcileWordpress) genReadyCondition(w *examplev1.Wordpress) (condv1.Condition, string, int32, error) {
+	cond := condv1.Condition{
+		Type:               readyCondition,
+		Status:             corev1.ConditionTrue,
+		Reason:             condv1.ConditionReason("DeploymentsAvailable"),
+		Message:            "mysql and wordpress are available",
+		LastTransitionTime: metav1.Now(),
+	}
+	version := ""
+	replicas := int32(0)
+
+	notReady := []string{}
+	probes := []string{}
+	tiers := map[string]map[string]string{
+		"wordpress-mysql": MysqlLabels(),
+		"wordpress":       WordpressLabels(),
+	}
+	for _, name := range []string{"wordpress-mysql", "wordpress"} {
+		deployment := &appsv1.Deployment{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: name}, deployment)
+		if errors.IsNotFound(err) {
+			notReady = append(notReady, fmt.Sprintf("%s is not created", name))
+			continue
+		} else if err != nil {
+			return cond, "", 0, err
+		}
+
+		if message := deploymentUnavailable(deployment); message != "" {
+			notReady = append(notReady, message)
+		}
+		if deployment.Status.ReadyReplicas < deployment.Status.Replicas {
+			messages, err := r.genProbeMessages(w, tiers[name])
+			if err != nil {
+				return cond, "", 0, err
+			}
+			probes = append(probes, messages...)
+		}
+		if name == "wordpress" {
+			version = wordpressVersion(deployment)
+			replicas = deployment.Status.Replicas
+		}
+	}
+
+	if len(notReady) > 0 {
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = condv1.ConditionReason("DeploymentsUnavailable")
+		cond.Message = strings.Join(notReady, ", ")
+	}
+	if len(probes) > 0 {
+		cond.Message = fmt.Sprintf("%s: %s", cond.Message, strings.Join(probes, ", "))
+	}
+	return cond, version, replicas, nil
+}
+
+// returns why a Deployment is not available, or "" if all its desired
+// replicas are available. A Deployment scaled to 0 is not available.
+func deploymentUnavailable(deployment *appsv1.Deployment) string {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	if desired == 0 {
+		return fmt.Sprintf("%s is scaled to 0", deployment.Name)
+	}
+	if deployment.Status.AvailableReplicas < desired {
+		return fmt.Sprintf("%s has %d of %d replicas available", deployment.Name, deployment.Status.AvailableReplicas, desired)
+	}
+	return ""
+}
+
+// returns why the pods matching labels are not ready, from the state of
+// their containers: a failing startup or readiness probe, or a waiting reason
+// like CrashLoopBackOff, with the restarts caused by the liveness probe
+func (r *ReconcileWordpress) genProbeMessages(w *examplev1.Wordpress, labels map[string]string) ([]string, error) {
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, client.InNamespace(w.Namespace), client.MatchingLabels(labels))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []string{}
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				continue
+			}
+
+			reason := "readiness probe failing"
+			if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+				reason = status.State.Waiting.Reason
+			} else if status.Started != nil && !*status.Started {
+				reason = "startup probe pending"
+			}
+			if status.RestartCount > 0 {
+				reason = fmt.Sprintf("%s after %d restarts", reason, status.RestartCount)
+			}
+			messages = append(messages, fmt.Sprintf("pod %s %s", pod.Name, reason))
+		}
+	}
+	return messages, nil
+}
+
+// returns the image tag of the wordpress container, looked up by name as a
+// podTemplatePatch may add sidecars before it, or "" if there is none
+func wordpressVersion(deployment *appsv1.Deployment) string {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "wordpress" {
+			return imageTag(container.Image)
+		}
+	}
+	return ""
+}
+
+// returns the tag of an image reference, or "latest" without a tag
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+// update the url, endpoints, version, replicas, selector, preset and Ready condition in the status
+func (r *ReconcileWordpress) updateReadyStatus(w *examplev1.Wordpress) error {
+	endpoints, err := r.genEndpoints(w)
+	if err != nil {
+		return err
+	}
+	cond, version, replicas, err := r.genReadyCondition(w)
+	if err != nil {
+		return err
+	}
+
+	url := ""
+	if len(endpoints) > 0 {
+		url = endpoints[0]
+	}
+
+	changed := w.Status.Conditions.SetCondition(cond)
+	selector := labels.SelectorFromSet(WordpressLabels()).String()
+	if w.Status.URL != url || w.Status.Version != version || !equality.Semantic.DeepEqual(w.Status.Endpoints, endpoints) ||
+		w.Status.Replicas != replicas || w.Status.Selector != selector || w.Status.Preset != presetName(w) {
+		w.Status.URL = url
+		w.Status.Endpoints = endpoints
+		w.Status.Version = version
+		w.Status.Replicas = replicas
+		w.Status.Selector = selector
+		w.Status.Preset = presetName(w)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	err = r.client.Status().Update(context.TODO(), w)
+	if err != nil {
+		r.logger.Error(err, "Failed to update wordpress Status")
+	}
+	return err
+}
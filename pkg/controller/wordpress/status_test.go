@@ -0,0 +1,87 @@
+package wordpress
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeploymentUnavailable(t *testing.T) {
+	replicas := func(n int32) *int32 { return &n }
+
+	tests := []struct {
+		name      string
+		replicas  *int32
+		available int32
+		want      string
+	}{
+		{name: "available", replicas: replicas(1), available: 1},
+		{name: "default replicas", available: 1},
+		{name: "all of several available", replicas: replicas(3), available: 3},
+		{name: "more available while rolling out", replicas: replicas(2), available: 3},
+		{name: "none available", replicas: replicas(1), want: "wordpress has 0 of 1 replicas available"},
+		{name: "some available", replicas: replicas(3), available: 1, want: "wordpress has 1 of 3 replicas available"},
+		{name: "scaled to 0", replicas: replicas(0), want: "wordpress is scaled to 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "wordpress"},
+				Spec:       appsv1.DeploymentSpec{Replicas: tt.replicas},
+				Status:     appsv1.DeploymentStatus{AvailableReplicas: tt.available},
+			}
+			if got := deploymentUnavailable(deployment); got != tt.want {
+				t.Errorf("deploymentUnavailable() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "wordpress:4.8-apache", want: "4.8-apache"},
+		{image: "wordpress", want: "latest"},
+		{image: "registry.example.com:5000/wordpress", want: "latest"},
+		{image: "registry.example.com:5000/wordpress:5.4@sha256:abc", want: "5.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := imageTag(tt.image); got != tt.want {
+				t.Errorf("imageTag() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordpressVersion(t *testing.T) {
+	wordpress := corev1.Container{Name: "wordpress", Image: "wordpress:5.4-apache"}
+	sidecar := corev1.Container{Name: "exporter", Image: "apache-exporter:0.8"}
+
+	tests := []struct {
+		name       string
+		containers []corev1.Container
+		want       string
+	}{
+		{name: "wordpress only", containers: []corev1.Container{wordpress}, want: "5.4-apache"},
+		{name: "sidecar first", containers: []corev1.Container{sidecar, wordpress}, want: "5.4-apache"},
+		{name: "no wordpress container", containers: []corev1.Container{sidecar}},
+		{name: "no containers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = tt.containers
+			if got := wordpressVersion(deployment); got != tt.want {
+				t.Errorf("wordpressVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
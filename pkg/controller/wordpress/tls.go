@@ -0,0 +1,228 @@
+package wordpress
+
+import (
+	"context"
+	"time"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// cert-manager Certificates and Gateway API ReferenceGrants are handled as
+// unstructured objects, like HTTPRoutes
+var certificateGVK = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+var referenceGrantGVK = schema.GroupVersionKind{
+	Group:   "gateway.networking.k8s.io",
+	Version: "v1beta1",
+	Kind:    "ReferenceGrant",
+}
+
+// default name of the Secret the Certificate is stored in
+const defaultTLSSecretName = "wordpress-tls"
+
+// returns the name of the Secret with the certificate for the site, from
+// spec.tls or spec.ingress.tlsSecretName, or "" without TLS
+func wordpressTLSSecretName(w *examplev1.Wordpress) string {
+	if w.Spec.TLS != nil {
+		if w.Spec.TLS.SecretName != "" {
+			return w.Spec.TLS.SecretName
+		}
+		return defaultTLSSecretName
+	}
+	if w.Spec.Ingress != nil {
+		return w.Spec.Ingress.TLSSecretName
+	}
+	return ""
+}
+
+// returns the hostnames of spec.ingress and spec.gateway
+func wordpressHostnames(w *examplev1.Wordpress) []string {
+	hostnames := []string{}
+	if w.Spec.Ingress != nil {
+		for _, host := range w.Spec.Ingress.Hosts {
+			if !contains(hostnames, host) {
+				hostnames = append(hostnames, host)
+			}
+		}
+	}
+	if w.Spec.Gateway != nil {
+		for _, host := range w.Spec.Gateway.Hostnames {
+			if !contains(hostnames, host) {
+				hostnames = append(hostnames, host)
+			}
+		}
+	}
+	return hostnames
+}
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile Wordpress Certificate
+/////////////////////////////////////////////////////////////////////
+
+// returns the wordpress Certificate object
+func (r *ReconcileWordpress) genWordpressCertificate(w *examplev1.Wordpress) *unstructured.Unstructured {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetName("wordpress")
+	certificate.SetNamespace(w.Namespace)
+	certificate.SetLabels(map[string]string{"app": "wordpress"})
+
+	spec := w.Spec.TLS
+	if spec == nil {
+		return certificate
+	}
+
+	kind := spec.IssuerRef.Kind
+	if kind == "" {
+		kind = "Issuer"
+	}
+	group := spec.IssuerRef.Group
+	if group == "" {
+		group = certificateGVK.Group
+	}
+
+	dnsNames := []interface{}{}
+	for _, hostname := range wordpressHostnames(w) {
+		dnsNames = append(dnsNames, hostname)
+	}
+
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName": wordpressTLSSecretName(w),
+		"dnsNames":   dnsNames,
+		"issuerRef": map[string]interface{}{
+			"name":  spec.IssuerRef.Name,
+			"kind":  kind,
+			"group": group,
+		},
+	}
+	return certificate
+}
+
+// returns the ReferenceGrant allowing Gateways in the namespace of
+// spec.gateway to use the certificate Secret in their listeners
+func (r *ReconcileWordpress) genTLSReferenceGrant(w *examplev1.Wordpress) (*unstructured.Unstructured, bool) {
+	grant := &unstructured.Unstructured{}
+	grant.SetGroupVersionKind(referenceGrantGVK)
+	grant.SetName("wordpress-tls")
+	grant.SetNamespace(w.Namespace)
+	grant.SetLabels(map[string]string{"app": "wordpress"})
+
+	if w.Spec.TLS == nil || w.Spec.Gateway == nil || gatewayNamespace(w) == w.Namespace {
+		return grant, false
+	}
+
+	grant.Object["spec"] = map[string]interface{}{
+		"from": []interface{}{
+			map[string]interface{}{
+				"group":     httpRouteGVK.Group,
+				"kind":      "Gateway",
+				"namespace": gatewayNamespace(w),
+			},
+		},
+		"to": []interface{}{
+			map[string]interface{}{
+				"group": "",
+				"kind":  "Secret",
+				"name":  wordpressTLSSecretName(w),
+			},
+		},
+	}
+	return grant, true
+}
+
+// Creates, Updates or Deletes the Wordpress Certificate and ReferenceGrant
+// objects. Without hostnames no Certificate is issued, and status.tls
+// reports why.
+func (r *ReconcileWordpress) reconcileWordpressCertificate(w *examplev1.Wordpress) error {
+	certificate := r.genWordpressCertificate(w)
+	err := r.reconcileUnstructured(w, certificate, w.Spec.TLS != nil && w.ValidateTLS() == nil, "Certificate")
+	if err != nil {
+		return err
+	}
+
+	grant, wanted := r.genTLSReferenceGrant(w)
+	return r.reconcileUnstructured(w, grant, wanted, "ReferenceGrant")
+}
+
+/////////////////////////////////////////////////////////////////////
+// Wordpress TLS Status
+/////////////////////////////////////////////////////////////////////
+
+// returns the status of the wordpress Certificate
+func (r *ReconcileWordpress) genTLSStatus(w *examplev1.Wordpress) (*examplev1.WordpressTLSStatus, error) {
+	if err := w.ValidateTLS(); err != nil {
+		return &examplev1.WordpressTLSStatus{Message: err.Error()}, nil
+	}
+
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: "wordpress"}, certificate)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return &examplev1.WordpressTLSStatus{Message: "Certificate not found"}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	tls := &examplev1.WordpressTLSStatus{}
+	conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		tls.Ready = condition["status"] == "True"
+		if message, ok := condition["message"].(string); ok {
+			tls.Message = message
+		}
+	}
+	tls.NotAfter = nestedTime(certificate, "status", "notAfter")
+	tls.RenewalTime = nestedTime(certificate, "status", "renewalTime")
+	return tls, nil
+}
+
+// returns an RFC3339 time field of an unstructured object, or nil
+func nestedTime(obj *unstructured.Unstructured, fields ...string) *metav1.Time {
+	value, found, _ := unstructured.NestedString(obj.Object, fields...)
+	if !found {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: t}
+}
+
+// update status.tls from the wordpress Certificate
+func (r *ReconcileWordpress) updateTLSStatus(w *examplev1.Wordpress) error {
+	var tls *examplev1.WordpressTLSStatus
+	if w.Spec.TLS != nil {
+		var err error
+		tls, err = r.genTLSStatus(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	if equality.Semantic.DeepEqual(w.Status.TLS, tls) {
+		return nil
+	}
+	w.Status.TLS = tls
+	err := r.client.Status().Update(context.TODO(), w)
+	if err != nil {
+		r.logger.Error(err, "Failed to update wordpress TLS Status")
+	}
+	return err
+}
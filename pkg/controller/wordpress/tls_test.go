@@ -0,0 +1,132 @@
+package wordpress
+
+import (
+	"context"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileWordpressCertificate(t *testing.T) {
+	r, stop := startTestEnv(t)
+	defer stop()
+	ctx := context.TODO()
+
+	getCertificate := func(namespace string) (*unstructured.Unstructured, error) {
+		certificate := &unstructured.Unstructured{}
+		certificate.SetGroupVersionKind(certificateGVK)
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "wordpress"}, certificate)
+		return certificate, err
+	}
+
+	t.Run("issued", func(t *testing.T) {
+		w := createTestWordpress(t, r, "tls-issued", examplev1.WordpressSpec{
+			Ingress: &examplev1.WordpressIngressSpec{Hosts: []string{"blog.example.com"}},
+			TLS:     &examplev1.WordpressTLSSpec{IssuerRef: examplev1.WordpressIssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"}},
+		})
+
+		if err := r.reconcileWordpressCertificate(w); err != nil {
+			t.Fatal(err)
+		}
+		certificate, err := getCertificate(w.Namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+		dnsNames, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "dnsNames")
+		issuerKind, _, _ := unstructured.NestedString(certificate.Object, "spec", "issuerRef", "kind")
+		if secretName != defaultTLSSecretName || len(dnsNames) != 1 || dnsNames[0] != "blog.example.com" || issuerKind != "ClusterIssuer" {
+			t.Fatalf("certificate spec = %v", certificate.Object["spec"])
+		}
+
+		// not issued yet
+		if err := r.updateTLSStatus(w); err != nil {
+			t.Fatal(err)
+		}
+		if w.Status.TLS == nil || w.Status.TLS.Ready {
+			t.Fatalf("status.tls = %+v, want not ready", w.Status.TLS)
+		}
+
+		// issue the certificate the way cert-manager does
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: w.Namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("certificate"),
+				corev1.TLSPrivateKeyKey: []byte("key"),
+			},
+		}
+		if err := r.client.Create(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+		certificate.Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True", "message": "Certificate is up to date and has not expired"},
+			},
+			"notAfter":    "2026-01-12T10:00:00Z",
+			"renewalTime": "2025-12-13T10:00:00Z",
+		}
+		if err := r.client.Status().Update(ctx, certificate); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.updateTLSStatus(w); err != nil {
+			t.Fatal(err)
+		}
+		stored := &examplev1.Wordpress{}
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: w.Namespace, Name: w.Name}, stored); err != nil {
+			t.Fatal(err)
+		}
+		tls := stored.Status.TLS
+		if tls == nil || !tls.Ready || tls.NotAfter == nil || tls.NotAfter.Format("2006-01-02") != "2026-01-12" || tls.RenewalTime == nil {
+			t.Fatalf("status.tls = %+v, want ready until 2026-01-12", tls)
+		}
+
+		// the Ingress uses the issued Secret
+		ingress := r.genWordpressIngress(stored)
+		ingressTLS, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "tls")
+		if len(ingressTLS) != 1 || ingressTLS[0].(map[string]interface{})["secretName"] != secret.Name {
+			t.Errorf("ingress tls = %v, want secret %s", ingressTLS, secret.Name)
+		}
+
+		// removing spec.tls deletes the Certificate and clears the status
+		stored.Spec.TLS = nil
+		if err := r.reconcileWordpressCertificate(stored); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := getCertificate(w.Namespace); !errors.IsNotFound(err) {
+			t.Errorf("certificate not deleted: %v", err)
+		}
+		if err := r.updateTLSStatus(stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status.TLS != nil {
+			t.Errorf("status.tls = %+v, want nil", stored.Status.TLS)
+		}
+	})
+
+	t.Run("no hostnames", func(t *testing.T) {
+		w := createTestWordpress(t, r, "tls-no-hostnames", examplev1.WordpressSpec{
+			TLS: &examplev1.WordpressTLSSpec{IssuerRef: examplev1.WordpressIssuerReference{Name: "letsencrypt"}},
+		})
+
+		if err := r.reconcileWordpressCertificate(w); err != nil {
+			t.Fatalf("reconcileWordpressCertificate() = %v, want no error", err)
+		}
+		if _, err := getCertificate(w.Namespace); !errors.IsNotFound(err) {
+			t.Errorf("certificate created without hostnames: %v", err)
+		}
+		if err := r.updateTLSStatus(w); err != nil {
+			t.Fatal(err)
+		}
+		if w.Status.TLS == nil || w.Status.TLS.Ready || w.Status.TLS.Message != w.ValidateTLS().Error() {
+			t.Errorf("status.tls = %+v, want the validation error", w.Status.TLS)
+		}
+	})
+}
@@ -2,19 +2,32 @@ package wordpress
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
 	"os"
 	"fmt"
+	"sort"
+	"strings"
 
 	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
 	condv1 "github.com/operator-framework/operator-sdk/pkg/status"
 
 	resource "k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/equality"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -39,6 +52,24 @@ func contains(list []string, s string) bool {
 	return false
 }
 
+// MysqlLabels returns the labels of the mysql pods, selected by the mysql
+// Deployment, Service and NetworkPolicy, and by WordpressSnapshots
+func MysqlLabels() map[string]string {
+	return map[string]string{
+		"app":  "wordpress",
+		"tier": "mysql",
+	}
+}
+
+// WordpressLabels returns the labels of the wordpress pods, selected by the
+// wordpress Deployment, Service and NetworkPolicy, and by WordpressSnapshots
+func WordpressLabels() map[string]string {
+	return map[string]string{
+		"app":  "wordpress",
+		"tier": "frontend",
+	}
+}
+
 // Add creates a new Wordpress Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
@@ -47,7 +78,7 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileWordpress{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileWordpress{client: mgr.GetClient(), apiReader: mgr.GetAPIReader(), scheme: mgr.GetScheme()}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -82,6 +113,15 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to the ServiceAccount for mysql and wordpress
+	err = c.Watch(&source.Kind{Type: &corev1.ServiceAccount{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &examplev1.Wordpress{},
+	})
+	if err != nil {
+		return err
+	}
+
 	// Watch for changes to PersistentVolumeClaims for mysql and wordpress
 	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
@@ -91,9 +131,102 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to the Ingress for wordpress, if networking.k8s.io/v1 is served
+	err = watchUnstructured(mgr, c, ingressGVK)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the HTTPRoute for wordpress, if the Gateway API is installed
+	err = watchUnstructured(mgr, c, httpRouteGVK)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the HorizontalPodAutoscaler for wordpress, if autoscaling/v2 is served
+	err = watchUnstructured(mgr, c, hpaGVK)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the PodDisruptionBudgets for mysql and wordpress, if policy/v1 is served
+	err = watchUnstructured(mgr, c, pdbGVK)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the Certificate for wordpress, if cert-manager is installed
+	err = watchUnstructured(mgr, c, certificateGVK)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to NetworkPolicies for mysql and wordpress
+	err = c.Watch(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &examplev1.Wordpress{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for clones and promotions of a site, to let their Jobs through its
+	// mysql NetworkPolicy
+	err = c.Watch(&source.Kind{Type: &examplev1.Wordpress{}}, handler.EnqueueRequestsFromMapFunc(
+		func(a client.Object) []reconcile.Request {
+			w := a.(*examplev1.Wordpress)
+			if w.Spec.CloneFrom == nil || w.Spec.CloneFrom.Namespace == w.Namespace {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{
+				Namespace: w.Spec.CloneFrom.Namespace,
+				Name:      w.Spec.CloneFrom.Name,
+			}}}
+		}))
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &examplev1.WordpressPromotion{}}, handler.EnqueueRequestsFromMapFunc(
+		func(a client.Object) []reconcile.Request {
+			p := a.(*examplev1.WordpressPromotion)
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{
+				Namespace: p.Spec.Source.Namespace,
+				Name:      p.Spec.Source.Name,
+			}}}
+		}))
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Jobs cloning mysql and wordpress
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &examplev1.Wordpress{},
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// watch objects of an optional CRD or API version owned by Wordpress, if it
+// is served
+func watchUnstructured(mgr manager.Manager, c controller.Controller, gvk schema.GroupVersionKind) error {
+	_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		log.Info("API is not served, not watching", "Kind", gvk.Kind, "Group", gvk.Group, "Version", gvk.Version)
+		return nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return c.Watch(&source.Kind{Type: obj}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &examplev1.Wordpress{},
+	})
+}
+
 // blank assignment to verify that ReconcileWordpress implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileWordpress{}
 
@@ -102,6 +235,8 @@ type ReconcileWordpress struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client  client.Client
+	// reads objects the cache doesn't hold, like the Namespace
+	apiReader client.Reader
 	scheme *runtime.Scheme
 	logger  logr.Logger
 }
@@ -111,16 +246,24 @@ type ReconcileWordpress struct {
 //
 // We need to reconcile:
 //   - mysql secret (mysql-pass)
+//   - networkpolicies mysql and wordpress (if spec.networkPolicy is enabled)
 //   - pvc mysql
 //   - pvc wordpress
 //   - deployment mysql
 //   - deployment wordpress
+//   - horizontalpodautoscaler wordpress (if spec.wordpress.autoscaling is set)
+//   - poddisruptionbudgets mysql and wordpress
 //   - service mysql (ClusterIP)
-//   - service wordpress (LoadBalancer)
+//   - service wordpress (LoadBalancer by default, see spec.service)
+//   - configmap wordpress (mu-plugin setting the site URL)
+//   - ingress wordpress (if spec.ingress is set)
+//   - httproute wordpress (if spec.gateway is set)
+//   - certificate wordpress (if spec.tls is set)
+//   - clone jobs, when cloning another Wordpress instance
 // Note:
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
-func (r *ReconcileWordpress) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+func (r *ReconcileWordpress) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	r.logger = log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	r.logger.Info("Reconciling Wordpress")
 
@@ -133,6 +276,47 @@ func (r *ReconcileWordpress) Reconcile(request reconcile.Request) (reconcile.Res
 		return reconcile.Result{}, err
 	}
 
+	// Check if Wordpress instance is marked to be deleted, before
+	// reconciling the objects the finalizer deletes
+	isWordpressMarkedToBeDeleted := instance.GetDeletionTimestamp() != nil
+	if isWordpressMarkedToBeDeleted {
+		if contains(instance.GetFinalizers(), wordpressFinalizer) {
+			// Run finalization logic for wordpressFinalizer. If the
+			// finalization logic fails, don't remove the finalizer so
+			// that we can retry during the next reconciliation.
+			finalized, err := r.finalizeWordpress(instance)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if !finalized {
+				// poll the final backup, WordpressSnapshots are not watched
+				return reconcile.Result{RequeueAfter: finalBackupPollInterval}, nil
+			}
+
+			// Remove wordpressFinalizer. Once all finalizers have been
+			// removed, the object will be deleted.
+			controllerutil.RemoveFinalizer(instance, wordpressFinalizer)
+			err = r.client.Update(context.TODO(), instance)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		return reconcile.Result{}, nil
+	}
+
+	// Add finalizer for this CR before creating any object
+	if !contains(instance.GetFinalizers(), wordpressFinalizer) {
+		if err := r.addFinalizer(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// report whether the admission webhook enforces deletionProtection
+	err = r.updateDeletionProtectedStatus(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
 	// reconcile secret
 	err = r.reconcileSecret(instance)
 	if err != nil {
@@ -140,6 +324,22 @@ func (r *ReconcileWordpress) Reconcile(request reconcile.Request) (reconcile.Res
 	}
 	r.updateStatus(instance, "secret")
 
+	// reconcile ServiceAccount before the pods are created
+	err = r.reconcileServiceAccount(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	r.updateStatus(instance, "serviceAccount")
+
+	// reconcile NetworkPolicies before the pods are created
+	err = r.reconcileNetworkPolicies(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if networkPolicyEnabled(instance) {
+		r.updateStatus(instance, "networkPolicy")
+	}
+
 	// reconcile PVC for Mysql
 	err = r.reconcileMysqlPVC(instance)
 	if err != nil {
@@ -154,10 +354,16 @@ func (r *ReconcileWordpress) Reconcile(request reconcile.Request) (reconcile.Res
 	}
 	r.updateStatus(instance, "wordpressPVC")
 
+	// pin the images to digests before the Deployments are created
+	err = r.reconcileImageDigests(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
 	// reconcile deployment for Mysql
 	err = r.reconcileMysqlDeployment(instance)
 	if err != nil {
-		return reconcile.Result{}, err
+		return reconcile.Result{}, r.reportPodTemplatePatchError(instance, err)
 	}
 	r.updateStatus(instance, "mysqlDeployment")
 
@@ -168,12 +374,48 @@ func (r *ReconcileWordpress) Reconcile(request reconcile.Request) (reconcile.Res
 	}
 	r.updateStatus(instance, "mysqlService")
 
+	// clone another Wordpress instance before creating the Wordpress deployment
+	cloned, err := r.reconcileClone(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// reconcile configmap for Wordpress
+	err = r.reconcileWordpressConfigMap(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	r.updateStatus(instance, "wordpressConfigMap")
+
 	// reconcile deployment for Wordpress
-	err = r.reconcileWordpressDeployment(instance)
+	if cloned {
+		err = r.reconcileWordpressDeployment(instance)
+		if err != nil {
+			return reconcile.Result{}, r.reportPodTemplatePatchError(instance, err)
+		}
+		r.updateStatus(instance, "wordpressDeployment")
+	}
+
+	// reconcile HorizontalPodAutoscaler for Wordpress
+	err = r.reconcileWordpressHPA(instance)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
-	r.updateStatus(instance, "wordpressDeployment")
+	if instance.Autoscaled() {
+		r.updateStatus(instance, "wordpressHPA")
+	}
+
+	// reconcile PodDisruptionBudgets for Mysql and Wordpress
+	served, err := r.reconcilePodDisruptionBudgets(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if served {
+		r.updateStatus(instance, "podDisruptionBudget")
+	} else {
+		r.updateStatusNotCreated(instance, "podDisruptionBudget", "APINotServed",
+			"policy/v1 PodDisruptionBudgets are not served by the cluster")
+	}
 
 	// reconcile service for Wordpress
 	err = r.reconcileWordpressService(instance)
@@ -182,33 +424,47 @@ func (r *ReconcileWordpress) Reconcile(request reconcile.Request) (reconcile.Res
 	}
 	r.updateStatus(instance, "wordpressService")
 
-	// Check if Wordpress instance is marked to be deleted
-	isWordpressMarkedToBeDeleted := instance.GetDeletionTimestamp() != nil
-	if isWordpressMarkedToBeDeleted {
-		if contains(instance.GetFinalizers(), wordpressFinalizer) {
-			// Run finalization logic for wordpressFinalizer. If the
-			// finalization logic fails, don't remove the finalizer so
-			// that we can retry during the next reconciliation.
-			if err := r.finalizeWordpress(instance); err != nil {
-				return reconcile.Result{}, err
-			}
+	// reconcile ingress for Wordpress
+	err = r.reconcileWordpressIngress(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if instance.Spec.Ingress != nil {
+		r.updateStatus(instance, "wordpressIngress")
+	}
 
-			// Remove wordpressFinalizer. Once all finalizers have been
-			// removed, the object will be deleted.
-			controllerutil.RemoveFinalizer(instance, wordpressFinalizer)
-			err := r.client.Update(context.TODO(), instance)
-			if err != nil {
-				return reconcile.Result{}, err
-			}
-		}
-		return reconcile.Result{}, nil
+	// reconcile HTTPRoute for Wordpress
+	err = r.reconcileWordpressHTTPRoute(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if instance.Spec.Gateway != nil {
+		r.updateStatus(instance, "wordpressHTTPRoute")
 	}
 
-	// Add finalizer for this CR
-	if !contains(instance.GetFinalizers(), wordpressFinalizer) {
-		if err := r.addFinalizer(instance); err != nil {
-			return reconcile.Result{}, err
-		}
+	// reconcile certificate for Wordpress
+	err = r.reconcileWordpressCertificate(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if instance.Spec.TLS != nil && instance.ValidateTLS() == nil {
+		r.updateStatus(instance, "wordpressCertificate")
+	}
+	err = r.updateTLSStatus(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// report where the site is reachable and whether it is ready
+	err = r.updateReadyStatus(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// report the Pod Security Standard level of the pods
+	err = r.updatePodSecurityStatus(instance)
+	if err != nil {
+		return reconcile.Result{}, err
 	}
 
 	return reconcile.Result{}, nil
@@ -225,7 +481,7 @@ func (r* ReconcileWordpress) findWordpressInstance(request *reconcile.Request) (
 /////////////////////////////////////////////////////////////////////
 // CreateObject creates an object
 /////////////////////////////////////////////////////////////////////
-func (r* ReconcileWordpress) CreateObject(obj runtime.Object, kind string) error {
+func (r* ReconcileWordpress) CreateObject(obj client.Object, kind string) error {
 	accessor, err := meta.Accessor(obj)
 	if err != nil {
 		r.logger.Error(err, "failed to get meta information", "Kind", kind)
@@ -246,10 +502,30 @@ func (r* ReconcileWordpress) CreateObject(obj runtime.Object, kind string) error
 	return nil
 }
 
+/////////////////////////////////////////////////////////////////////
+// UpdateObject updates an object
+/////////////////////////////////////////////////////////////////////
+func (r* ReconcileWordpress) UpdateObject(obj client.Object, kind string) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		r.logger.Error(err, "failed to get meta information", "Kind", kind)
+		return err
+	}
+
+	err = r.client.Update(context.TODO(), obj)
+	if err != nil {
+		r.logger.Error(err, "failed to update object", "Kind", kind, "Name", accessor.GetName())
+		return err
+	}
+
+	r.logger.Info("updated object", "Kind", kind, "Name", accessor.GetName())
+	return nil
+}
+
 /////////////////////////////////////////////////////////////////////
 // DeleteObject deletes an object
 /////////////////////////////////////////////////////////////////////
-func (r* ReconcileWordpress) DeleteObject(obj runtime.Object, kind string) error {
+func (r* ReconcileWordpress) DeleteObject(obj client.Object, kind string) error {
 	accessor, err := meta.Accessor(obj)
 	if err != nil {
 		r.logger.Error(err, "failed to get meta information", "Kind", kind)
@@ -268,6 +544,100 @@ func (r* ReconcileWordpress) DeleteObject(obj runtime.Object, kind string) error
 	}
 }
 
+/////////////////////////////////////////////////////////////////////
+// ReconcileUnstructured creates, updates or deletes an object of an
+// optional CRD owned by the Wordpress instance
+/////////////////////////////////////////////////////////////////////
+
+// obj is deleted if wanted is false, otherwise it is created, or updated
+// when the hash of its generated spec and annotations changed, so defaults
+// applied by the API server are not overwritten. The annotations of obj
+// replace the ones it set before, other annotations are kept.
+func (r* ReconcileWordpress) reconcileUnstructured(w *examplev1.Wordpress, obj *unstructured.Unstructured, wanted bool, kind string) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, existing)
+	if meta.IsNoMatchError(err) {
+		if !wanted {
+			return nil
+		}
+		return fmt.Errorf("the CRD for %s is not installed", kind)
+	} else if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	if !wanted {
+		if found && metav1.IsControlledBy(existing, w) {
+			return r.DeleteObject(existing, kind)
+		}
+		return nil
+	}
+
+	// the hash of objects without annotations is the hash of their spec, as
+	// before annotations were supported
+	managed := obj.GetAnnotations()
+	var hashed interface{} = obj.Object["spec"]
+	if len(managed) > 0 {
+		hashed = map[string]interface{}{"spec": obj.Object["spec"], "annotations": managed}
+	}
+	hash, err := specHash(hashed)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{}
+	mergeManagedAnnotations(annotations, managed)
+	annotations[specHashKey] = hash
+	obj.SetAnnotations(annotations)
+	controllerutil.SetControllerReference(w, obj, r.scheme)
+
+	if !found {
+		return r.CreateObject(obj, kind)
+	}
+	if existing.GetAnnotations()[specHashKey] == hash {
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	annotations = updated.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	mergeManagedAnnotations(annotations, managed)
+	annotations[specHashKey] = hash
+	updated.SetAnnotations(annotations)
+	updated.SetLabels(obj.GetLabels())
+	updated.SetOwnerReferences(obj.GetOwnerReferences())
+	updated.Object["spec"] = obj.Object["spec"]
+	return r.UpdateObject(updated, kind)
+}
+
+// annotation on unstructured objects and the ServiceAccount listing the
+// annotations set by the operator
+const managedAnnotationsKey = "wordpress.example.com/annotations"
+
+// replace the annotations previously set by the operator by managed, and
+// record their keys
+func mergeManagedAnnotations(annotations map[string]string, managed map[string]string) {
+	if keys, ok := annotations[managedAnnotationsKey]; ok {
+		for _, key := range strings.Split(keys, ",") {
+			delete(annotations, key)
+		}
+		delete(annotations, managedAnnotationsKey)
+	}
+	if len(managed) == 0 {
+		return
+	}
+
+	keys := []string{}
+	for key, value := range managed {
+		annotations[key] = value
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	annotations[managedAnnotationsKey] = strings.Join(keys, ",")
+}
+
 /////////////////////////////////////////////////////////////////////
 // Reconcile Secret
 /////////////////////////////////////////////////////////////////////
@@ -309,14 +679,15 @@ func (r* ReconcileWordpress) reconcileSecret(w *examplev1.Wordpress) (error) {
 // Reconcile Mysql PVC
 /////////////////////////////////////////////////////////////////////
 
-// return mysql PVC object
-func (r* ReconcileWordpress) genMysqlPVC(w *examplev1.Wordpress) *corev1.PersistentVolumeClaim {
+// return mysql PVC object, populated from the snapshot's mysql VolumeSnapshot if not nil
+func (r* ReconcileWordpress) genMysqlPVC(w *examplev1.Wordpress, snapshot *examplev1.WordpressSnapshot) *corev1.PersistentVolumeClaim {
 	pvcSize := os.Getenv("WORDPRESS_PVC_SIZE")
 
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "mysql-pv-claim",
 			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{ corev1.ReadWriteOnce },
@@ -328,33 +699,50 @@ func (r* ReconcileWordpress) genMysqlPVC(w *examplev1.Wordpress) *corev1.Persist
 		},
 	}
 
+	if snapshot != nil {
+		pvc.Spec.DataSource = genVolumeSnapshotDataSource(snapshot.Status.MysqlVolumeSnapshot)
+	}
+
 	return pvc
 }
 
 // create or update mysql PVC object
 func (r* ReconcileWordpress) reconcileMysqlPVC(w *examplev1.Wordpress) (error) {
-	pvc := r.genMysqlPVC(w)
+	snapshot, err := r.findPVCSnapshot(w, "mysql-pv-claim")
+	if err != nil {
+		return err
+	}
+	pvc := r.genMysqlPVC(w, snapshot)
 
 	// create PVC
-	err := r.CreateObject(pvc, "PersistentVolumeClaim")
-	return err
+	err = r.CreateObject(pvc, "PersistentVolumeClaim")
+	if err != nil {
+		return err
+	}
+	return r.reconcilePVCLabels(pvc)
 }
 
 /////////////////////////////////////////////////////////////////////
 // Reconcile Wordpress PVC
 /////////////////////////////////////////////////////////////////////
 
-// return wordpress PVC object
-func (r* ReconcileWordpress) genWordpressPVC(w *examplev1.Wordpress) *corev1.PersistentVolumeClaim {
+// return wordpress PVC object, populated from the snapshot's wordpress VolumeSnapshot if not nil
+func (r* ReconcileWordpress) genWordpressPVC(w *examplev1.Wordpress, snapshot *examplev1.WordpressSnapshot) *corev1.PersistentVolumeClaim {
 	pvcSize := os.Getenv("WORDPRESS_PVC_SIZE")
 
+	accessMode := corev1.ReadWriteOnce
+	if rwxStorageClass(w) {
+		accessMode = corev1.ReadWriteMany
+	}
+
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "wp-pv-claim",
 			Namespace: w.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{ corev1.ReadWriteOnce },
+			AccessModes: []corev1.PersistentVolumeAccessMode{ accessMode },
 			Resources: corev1.ResourceRequirements{
 				Requests: corev1.ResourceList{
 					corev1.ResourceStorage: resource.MustParse(pvcSize),
@@ -363,16 +751,115 @@ func (r* ReconcileWordpress) genWordpressPVC(w *examplev1.Wordpress) *corev1.Per
 		},
 	}
 
+	if w.Spec.Wordpress != nil && w.Spec.Wordpress.StorageClassName != "" {
+		pvc.Spec.StorageClassName = &w.Spec.Wordpress.StorageClassName
+	}
+
+	if snapshot != nil {
+		pvc.Spec.DataSource = genVolumeSnapshotDataSource(snapshot.Status.WordpressVolumeSnapshot)
+	}
+
 	return pvc
 }
 
+// returns true if the storage class of spec.wordpress supports ReadWriteMany
+func rwxStorageClass(w *examplev1.Wordpress) bool {
+	if w.Spec.Wordpress == nil {
+		return false
+	}
+	return examplev1.IsRWXStorageClass(os.Getenv("WORDPRESS_RWX_STORAGE_CLASSES"), w.Spec.Wordpress.StorageClassName)
+}
+
+// returns true if the wordpress pods don't mount the wordpress PVC
+func mediaOffload(w *examplev1.Wordpress) bool {
+	return w.Spec.Wordpress != nil && w.Spec.Wordpress.MediaOffload
+}
+
 // create or update wordpress PVC
 func (r* ReconcileWordpress) reconcileWordpressPVC(w *examplev1.Wordpress) (error) {
-	pvc := r.genWordpressPVC(w)
+	snapshot, err := r.findPVCSnapshot(w, "wp-pv-claim")
+	if err != nil {
+		return err
+	}
+	pvc := r.genWordpressPVC(w, snapshot)
 
 	// create PVC
-	err := r.CreateObject(pvc, "PersistentVolumeClaim")
-	return err
+	err = r.CreateObject(pvc, "PersistentVolumeClaim")
+	if err != nil {
+		return err
+	}
+	return r.reconcilePVCLabels(pvc)
+}
+
+// add the labels of the wanted PVC to an existing PVC that lacks them, so
+// that PVCs created by older operator versions are matched by the
+// objectSelector of the PVC deletion protection webhook
+func (r* ReconcileWordpress) reconcilePVCLabels(pvc *corev1.PersistentVolumeClaim) error {
+	existing := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, existing)
+	if err != nil {
+		return err
+	}
+
+	update := false
+	for k, v := range pvc.Labels {
+		if existing.Labels[k] != v {
+			if existing.Labels == nil {
+				existing.Labels = map[string]string{}
+			}
+			existing.Labels[k] = v
+			update = true
+		}
+	}
+	if !update {
+		return nil
+	}
+	return r.UpdateObject(existing, "PersistentVolumeClaim")
+}
+
+/////////////////////////////////////////////////////////////////////
+// PVCs from WordpressSnapshot
+/////////////////////////////////////////////////////////////////////
+
+// return the Ready WordpressSnapshot to populate a PVC from, or nil if the
+// PVC already exists or no snapshot is requested
+func (r* ReconcileWordpress) findPVCSnapshot(w *examplev1.Wordpress, pvcName string) (*examplev1.WordpressSnapshot, error) {
+	if w.Spec.FromSnapshot == "" {
+		return nil, nil
+	}
+
+	// a dataSource is only used when the PVC is created
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: pvcName}, pvc)
+	if err == nil {
+		return nil, nil
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	snapshot := &examplev1.WordpressSnapshot{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: w.Spec.FromSnapshot}, snapshot)
+	if err != nil {
+		r.logger.Error(err, "failed to get WordpressSnapshot", "Name", w.Spec.FromSnapshot)
+		return nil, err
+	}
+
+	if snapshot.Status.Phase != examplev1.WordpressSnapshotReady {
+		return nil, fmt.Errorf("WordpressSnapshot %s is not Ready", snapshot.Name)
+	}
+
+	return snapshot, nil
+}
+
+// return a PVC dataSource referencing a VolumeSnapshot
+func genVolumeSnapshotDataSource(name string) *corev1.TypedLocalObjectReference {
+	apiGroup := "snapshot.storage.k8s.io"
+
+	return &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     name,
+	}
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -398,12 +885,9 @@ func (r *ReconcileWordpress) genMysqlDeployment(w *examplev1.Wordpress) *appsv1.
 	labels := map[string]string {
 		"app":  "wordpress",
 	}
-	matchlabels := map[string]string {
-		"app":  "wordpress",
-		"tier": "mysql",
-	}
+	matchlabels := MysqlLabels()
 
-	imageName  := os.Getenv("WORDPRESS_IMAGE_MYSQL")
+	imageName  := MysqlImage(w)
 	rootPasswordSecret := r.genRootPasswordSecret()
 
 	deployment := &appsv1.Deployment{
@@ -416,11 +900,16 @@ func (r *ReconcileWordpress) genMysqlDeployment(w *examplev1.Wordpress) *appsv1.
 			Selector: &metav1.LabelSelector{
 				MatchLabels: matchlabels,
 			},
+			// the ReadWriteOnce PVC can't be mounted by old and new pods on different nodes
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: matchlabels,
 				},
 				Spec: corev1.PodSpec{
+					ImagePullSecrets: imagePullSecrets(w),
 					Containers: []corev1.Container{{
 						Image:   imageName,
 						Name:    "mysql",
@@ -432,6 +921,7 @@ func (r *ReconcileWordpress) genMysqlDeployment(w *examplev1.Wordpress) *appsv1.
 							ContainerPort: 3306,
 							Name:          "mysql",
 						}},
+						Resources: mysqlResources(w),
 						VolumeMounts: []corev1.VolumeMount{{
 							Name:      "mysql-persistent-storage",
 							MountPath: "/var/lib/mysql",
@@ -449,6 +939,10 @@ func (r *ReconcileWordpress) genMysqlDeployment(w *examplev1.Wordpress) *appsv1.
 			},
 		},
 	}
+	setMysqlProbes(w, &deployment.Spec.Template.Spec.Containers[0])
+	setMysqlScheduling(w, &deployment.Spec.Template.Spec)
+	setMysqlSecurityContext(w, deployment)
+	setServiceAccount(w, &deployment.Spec.Template.Spec)
 
 	// Set Wordpress instance as the owner of the Deployment.
 	controllerutil.SetControllerReference(w, deployment, r.scheme)
@@ -457,10 +951,20 @@ func (r *ReconcileWordpress) genMysqlDeployment(w *examplev1.Wordpress) *appsv1.
 
 // create or update mysql deployment
 func (r* ReconcileWordpress) reconcileMysqlDeployment(w *examplev1.Wordpress) (error) {
+	err := validatePreset(w)
+	if err != nil {
+		return err
+	}
+
+	// replicas are not set, WordpressSnapshots scale mysql down while snapshotting
 	deployment := r.genMysqlDeployment(w)
+	err = patchMysqlPodTemplate(w, deployment)
+	if err != nil {
+		return err
+	}
 
-	// create Deployment
-	err := r.CreateObject(deployment, "Deployment")
+	// create or update Deployment
+	err = r.reconcileDeployment(deployment)
 	return err
 }
 
@@ -473,13 +977,17 @@ func (r *ReconcileWordpress) genWordpressDeployment(w *examplev1.Wordpress) *app
 	labels := map[string]string {
 		"app":  "wordpress",
 	}
-	matchlabels := map[string]string {
-		"app":  "wordpress",
-		"tier": "frontend",
-	}
+	matchlabels := WordpressLabels()
 
-	imageName  := os.Getenv("WORDPRESS_IMAGE_WORDPRESS")
+	imageName  := wordpressImage(w)
 	rootPasswordSecret := r.genRootPasswordSecret()
+	siteURL := wordpressSiteURL(w)
+	var replicas *int32
+	// the HorizontalPodAutoscaler owns the replicas of an autoscaled Deployment
+	if !w.Autoscaled() {
+		wordpressReplicas := w.WordpressReplicas()
+		replicas = &wordpressReplicas
+	}
 
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -488,14 +996,21 @@ func (r *ReconcileWordpress) genWordpressDeployment(w *examplev1.Wordpress) *app
 			Labels:    labels,
 		},
 		Spec: appsv1.DeploymentSpec{
+			Replicas: replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: matchlabels,
 			},
+			// the ReadWriteOnce PVC can't be mounted by old and new pods on different nodes
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: matchlabels,
+					Labels:      matchlabels,
+					Annotations: map[string]string{},
 				},
 				Spec: corev1.PodSpec{
+					ImagePullSecrets: imagePullSecrets(w),
 					Containers: []corev1.Container{{
 						Image:   imageName,
 						Name:    "wordpress",
@@ -513,6 +1028,7 @@ func (r *ReconcileWordpress) genWordpressDeployment(w *examplev1.Wordpress) *app
 							ContainerPort: 80,
 							Name:          "wordpress",
 						}},
+						Resources: wordpressResources(w),
 						VolumeMounts: []corev1.VolumeMount{{
 							Name:      "wordpress-persistent-storage",
 							MountPath: "/var/www/html",
@@ -530,6 +1046,79 @@ func (r *ReconcileWordpress) genWordpressDeployment(w *examplev1.Wordpress) *app
 			},
 		},
 	}
+	setWordpressProbes(w, &deployment.Spec.Template.Spec.Containers[0])
+	setWordpressScheduling(w, &deployment.Spec.Template.Spec)
+	setWordpressSecurityContext(w, deployment)
+	setServiceAccount(w, &deployment.Spec.Template.Spec)
+
+	// with media offload every pod gets its own copy of wordpress from the
+	// image, sharing the keys and salts so logins are valid on all pods
+	if mediaOffload(w) {
+		pod := &deployment.Spec.Template.Spec
+		pod.Volumes[0].VolumeSource = corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		}
+		for _, key := range saltKeys {
+			pod.Containers[0].Env = append(pod.Containers[0].Env, corev1.EnvVar{
+				Name: "WORDPRESS_" + key,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: saltsSecretName,
+						},
+						Key: key,
+					},
+				},
+			})
+		}
+	}
+
+	// pods don't share a ReadWriteOnce PVC, so they can be replaced one by one
+	if mediaOffload(w) || rwxStorageClass(w) {
+		deployment.Spec.Strategy = appsv1.DeploymentStrategy{
+			Type: appsv1.RollingUpdateDeploymentStrategyType,
+		}
+	}
+
+	// set WP_HOME and WP_SITEURL with the mu-plugin from the wordpress ConfigMap
+	if siteURL != "" {
+		pod := &deployment.Spec.Template.Spec
+		pod.Containers[0].Env = append(pod.Containers[0].Env,
+			corev1.EnvVar{
+				Name:  "WP_HOME",
+				Value: siteURL,
+			},
+			corev1.EnvVar{
+				Name:  "WP_SITEURL",
+				Value: siteURL,
+			},
+		)
+		// behind a TLS terminating proxy
+		if strings.HasPrefix(siteURL, "https://") {
+			pod.Containers[0].Env = append(pod.Containers[0].Env, corev1.EnvVar{
+				Name:  "FORCE_SSL_ADMIN",
+				Value: "true",
+			})
+		}
+		// restart wordpress when the mu-plugin changes, it is mounted with subPath
+		deployment.Spec.Template.Annotations[muPluginHashKey] = muPluginHash()
+		pod.Containers[0].VolumeMounts = append(pod.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "wordpress-mu-plugin",
+			MountPath: "/var/www/html/wp-content/mu-plugins/" + muPluginFile,
+			SubPath:   muPluginFile,
+			ReadOnly:  true,
+		})
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: "wordpress-mu-plugin",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "wordpress",
+					},
+				},
+			},
+		})
+	}
 
 	// Set Wordpress instance as the owner of the Deployment.
 	controllerutil.SetControllerReference(w, deployment, r.scheme)
@@ -538,23 +1127,221 @@ func (r *ReconcileWordpress) genWordpressDeployment(w *examplev1.Wordpress) *app
 
 // Creates or Updates a Wordpress Deployment object
 func (r* ReconcileWordpress) reconcileWordpressDeployment(w *examplev1.Wordpress) (error) {
+	err := r.validateWordpressReplicas(w)
+	if err != nil {
+		return err
+	}
+	err = validatePreset(w)
+	if err != nil {
+		return err
+	}
+
+	if mediaOffload(w) {
+		err = r.CreateObject(r.genSaltsSecret(w), "Secret")
+		if err != nil {
+			return err
+		}
+	}
+
 	deployment := r.genWordpressDeployment(w)
+	err = patchWordpressPodTemplate(w, deployment)
+	if err != nil {
+		return err
+	}
 
-	// create Wordpress Deployment
-	err := r.CreateObject(deployment, "Deployment")
+	// create or update Wordpress Deployment
+	err = r.reconcileDeployment(deployment)
 	return err
 }
 
+// returns an error if the wordpress pods would share a ReadWriteOnce PVC
+func (r* ReconcileWordpress) validateWordpressReplicas(w *examplev1.Wordpress) error {
+	err := w.ValidateReplicas(os.Getenv("WORDPRESS_RWX_STORAGE_CLASSES"))
+	if err != nil || w.MaxWordpressReplicas() <= 1 || mediaOffload(w) {
+		return err
+	}
+
+	// the PVC may have been created before spec.wordpress.storageClassName was set
+	pvc := &corev1.PersistentVolumeClaim{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: w.Namespace, Name: "wp-pv-claim"}, pvc)
+	if err != nil {
+		return err
+	}
+	for _, mode := range pvc.Spec.AccessModes {
+		if mode == corev1.ReadWriteMany {
+			return nil
+		}
+	}
+	return fmt.Errorf("more than 1 wordpress replica requires PVC wp-pv-claim to be ReadWriteMany")
+}
+
+// Secret with the wordpress keys and salts shared by pods with media offload
+const saltsSecretName = "wordpress-salts"
+
+// keys and salts of wp-config.php, set by the wordpress image from WORDPRESS_<key>
+var saltKeys = []string{
+	"AUTH_KEY", "SECURE_AUTH_KEY", "LOGGED_IN_KEY", "NONCE_KEY",
+	"AUTH_SALT", "SECURE_AUTH_SALT", "LOGGED_IN_SALT", "NONCE_SALT",
+}
+
+// returns the wordpress salts Secret object with random keys and salts
+func (r* ReconcileWordpress) genSaltsSecret(w *examplev1.Wordpress) *corev1.Secret {
+	salts := map[string]string{}
+	for _, key := range saltKeys {
+		value := make([]byte, 32)
+		rand.Read(value)
+		salts[key] = hex.EncodeToString(value)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      saltsSecretName,
+			Namespace: w.Namespace,
+		},
+		StringData: salts,
+	}
+
+	// Set Wordpress instance as the owner of the Secret.
+	controllerutil.SetControllerReference(w, secret, r.scheme)
+	return secret
+}
+
+// annotation on Deployments and unstructured objects with the hash of the generated spec
+const specHashKey = "wordpress.example.com/spec-hash"
+
+// returns a hash of a generated spec
+func specHash(spec interface{}) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	hash := fnv.New32a()
+	hash.Write(data)
+	return fmt.Sprintf("%08x", hash.Sum32()), nil
+}
+
+// creates the Deployment, or updates the replicas, pod template and strategy of the
+// existing Deployment when the generated spec changed. Replicas are left alone
+// if not set, e.g. when they are owned by a HorizontalPodAutoscaler
+func (r* ReconcileWordpress) reconcileDeployment(deployment *appsv1.Deployment) (error) {
+	hash, err := specHash(deployment.Spec)
+	if err != nil {
+		return err
+	}
+	deployment.Annotations = map[string]string{
+		specHashKey: hash,
+	}
+
+	existing := &appsv1.Deployment{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}, existing)
+	if errors.IsNotFound(err) {
+		return r.CreateObject(deployment, "Deployment")
+	} else if err != nil {
+		return err
+	}
+
+	if existing.Annotations[specHashKey] == hash {
+		return nil
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[specHashKey] = hash
+	if deployment.Spec.Replicas != nil {
+		existing.Spec.Replicas = deployment.Spec.Replicas
+	}
+	existing.Spec.Template = deployment.Spec.Template
+	existing.Spec.Strategy = deployment.Spec.Strategy
+	return r.UpdateObject(existing, "Deployment")
+}
+
+/////////////////////////////////////////////////////////////////////
+// Reconcile Wordpress ConfigMap
+/////////////////////////////////////////////////////////////////////
+
+// name of the mu-plugin in the wordpress ConfigMap
+const muPluginFile = "wordpress-operator.php"
+
+// annotation on the wordpress pod template with the hash of the mu-plugin
+const muPluginHashKey = "wordpress.example.com/mu-plugin-hash"
+
+// mu-plugin defining WP_HOME, WP_SITEURL and FORCE_SSL_ADMIN from the
+// environment, works with wp-config.php files created by any version of the
+// wordpress image. With FORCE_SSL_ADMIN, requests forwarded by the TLS
+// terminating Ingress or Gateway with X-Forwarded-Proto: https are https.
+const muPlugin = `<?php
+/*
+ * Plugin Name: wordpress-operator
+ * Description: Sets WP_HOME, WP_SITEURL and FORCE_SSL_ADMIN from the environment of the wordpress container.
+ */
+if (getenv('WP_HOME') && !defined('WP_HOME')) {
+	define('WP_HOME', getenv('WP_HOME'));
+}
+if (getenv('WP_SITEURL') && !defined('WP_SITEURL')) {
+	define('WP_SITEURL', getenv('WP_SITEURL'));
+}
+if (getenv('FORCE_SSL_ADMIN') === 'true') {
+	if (!defined('FORCE_SSL_ADMIN')) {
+		define('FORCE_SSL_ADMIN', true);
+	}
+	if (isset($_SERVER['HTTP_X_FORWARDED_PROTO']) && $_SERVER['HTTP_X_FORWARDED_PROTO'] === 'https') {
+		$_SERVER['HTTPS'] = 'on';
+	}
+}
+`
+
+// returns a hash of the mu-plugin
+func muPluginHash() string {
+	hash := fnv.New32a()
+	hash.Write([]byte(muPlugin))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// returns the wordpress ConfigMap object
+func (r* ReconcileWordpress) genWordpressConfigMap(w *examplev1.Wordpress) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "wordpress",
+			Namespace: w.Namespace,
+		},
+		Data: map[string]string{
+			muPluginFile: muPlugin,
+		},
+	}
+
+	// Set Wordpress instance as the owner of the ConfigMap.
+	controllerutil.SetControllerReference(w, configMap, r.scheme)
+	return configMap
+}
+
+// Creates or Updates a Wordpress ConfigMap object
+func (r* ReconcileWordpress) reconcileWordpressConfigMap(w *examplev1.Wordpress) (error) {
+	configMap := r.genWordpressConfigMap(w)
+
+	existing := &corev1.ConfigMap{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: configMap.Namespace, Name: configMap.Name}, existing)
+	if errors.IsNotFound(err) {
+		// create Wordpress ConfigMap
+		return r.CreateObject(configMap, "ConfigMap")
+	} else if err != nil {
+		return err
+	}
+
+	// update the mu-plugin written by an older operator
+	if equality.Semantic.DeepEqual(existing.Data, configMap.Data) {
+		return nil
+	}
+	existing.Data = configMap.Data
+	return r.UpdateObject(existing, "ConfigMap")
+}
+
 /////////////////////////////////////////////////////////////////////
 // Reconcile Mysql Service
 /////////////////////////////////////////////////////////////////////
 
 // returns a mysql service object
 func (r* ReconcileWordpress) genMysqlService(w *examplev1.Wordpress) *corev1.Service {
-	selector := map[string]string {
-		"app":  "wordpress",
-		"tier": "mysql",
-	}
+	selector := MysqlLabels()
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "wordpress-mysql",
@@ -590,12 +1377,24 @@ func (r* ReconcileWordpress) reconcileMysqlService(w *examplev1.Wordpress) (erro
 // Reconcile Wordpress Service
 /////////////////////////////////////////////////////////////////////
 
+// annotation on the wordpress Service listing the annotations set from spec.service
+const serviceAnnotationsKey = "wordpress.example.com/service-annotations"
+
 // create or update wordpress service
 func (r* ReconcileWordpress) genWordpressService(w *examplev1.Wordpress) *corev1.Service {
-	selector := map[string]string {
-		"app":  "wordpress",
-		"tier": "frontend",
+	selector := WordpressLabels()
+
+	spec := w.Spec.Service
+	if spec == nil {
+		spec = &examplev1.WordpressServiceSpec{}
 	}
+
+	serviceType := spec.Type
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeLoadBalancer
+	}
+	port := wordpressServicePort(w)
+
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "wordpress",
@@ -605,26 +1404,131 @@ func (r* ReconcileWordpress) genWordpressService(w *examplev1.Wordpress) *corev1
 			Selector: selector,
 			Ports: []corev1.ServicePort{
 				{
-					Port: 80,
-					Name: "wordpress",
+					Port:       port,
+					TargetPort: intstr.FromInt(80),
+					Protocol:   corev1.ProtocolTCP,
+					Name:       "wordpress",
 				},
 			},
-			Type: "LoadBalancer",
+			Type: serviceType,
 		},
 	}
 
+	if serviceType != corev1.ServiceTypeClusterIP {
+		service.Spec.Ports[0].NodePort = spec.NodePort
+		service.Spec.ExternalTrafficPolicy = spec.ExternalTrafficPolicy
+		if service.Spec.ExternalTrafficPolicy == "" {
+			service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+		}
+	}
+	if serviceType == corev1.ServiceTypeLoadBalancer {
+		service.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+	}
+
+	if len(spec.Annotations) > 0 {
+		keys := []string{}
+		service.Annotations = map[string]string{}
+		for key, value := range spec.Annotations {
+			service.Annotations[key] = value
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		service.Annotations[serviceAnnotationsKey] = strings.Join(keys, ",")
+	}
+
 	// Set Wordpress instance as the owner of the Service.
 	controllerutil.SetControllerReference(w, service, r.scheme)
 	return service
 }
 
+// returns the port of the wordpress Service
+func wordpressServicePort(w *examplev1.Wordpress) int32 {
+	if w.Spec.Service != nil && w.Spec.Service.Port != 0 {
+		return w.Spec.Service.Port
+	}
+	return 80
+}
+
+// apply the fields of the generated Service to the existing Service, keeping
+// its ClusterIP and any node port allocated by Kubernetes
+func mergeWordpressService(existing *corev1.Service, service *corev1.Service) {
+	// remove annotations previously set from spec.service
+	if keys, ok := existing.Annotations[serviceAnnotationsKey]; ok {
+		for _, key := range strings.Split(keys, ",") {
+			delete(existing.Annotations, key)
+		}
+		delete(existing.Annotations, serviceAnnotationsKey)
+	}
+	for key, value := range service.Annotations {
+		if existing.Annotations == nil {
+			existing.Annotations = map[string]string{}
+		}
+		existing.Annotations[key] = value
+	}
+
+	ports := []corev1.ServicePort{}
+	for _, port := range service.Spec.Ports {
+		if port.NodePort == 0 && service.Spec.Type != corev1.ServiceTypeClusterIP {
+			for _, existingPort := range existing.Spec.Ports {
+				if existingPort.Name == port.Name {
+					port.NodePort = existingPort.NodePort
+				}
+			}
+		}
+		ports = append(ports, port)
+	}
+
+	existing.Spec.Type = service.Spec.Type
+	existing.Spec.Ports = ports
+	existing.Spec.ExternalTrafficPolicy = service.Spec.ExternalTrafficPolicy
+	existing.Spec.LoadBalancerSourceRanges = service.Spec.LoadBalancerSourceRanges
+	if existing.Spec.Type != corev1.ServiceTypeLoadBalancer ||
+		existing.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal {
+		existing.Spec.HealthCheckNodePort = 0
+	}
+}
+
 // Creates or Updates a Wordpress Service object
 func (r* ReconcileWordpress) reconcileWordpressService(w *examplev1.Wordpress) (error) {
 	service := r.genWordpressService(w)
 
-	// create Mysql Service
-	err := r.CreateObject(service, "Service")
-	return err
+	existing := &corev1.Service{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, existing)
+	if errors.IsNotFound(err) {
+		// create Wordpress Service
+		return r.CreateObject(service, "Service")
+	} else if err != nil {
+		return err
+	}
+
+	// update the Service in place, so the load balancer is not recreated
+	updated := existing.DeepCopy()
+	mergeWordpressService(updated, service)
+	if equality.Semantic.DeepEqual(existing, updated) {
+		return nil
+	}
+	return r.UpdateObject(updated, "Service")
+}
+
+/////////////////////////////////////////////////////////////////////
+// Wordpress site URL
+/////////////////////////////////////////////////////////////////////
+
+// returns the site URL for the primary host of spec.ingress or spec.gateway,
+// or "" without hosts
+func wordpressSiteURL(w *examplev1.Wordpress) string {
+	scheme := "http"
+	if wordpressTLSSecretName(w) != "" {
+		scheme = "https"
+	}
+
+	if w.Spec.Ingress != nil && len(w.Spec.Ingress.Hosts) > 0 {
+		return fmt.Sprintf("%s://%s", scheme, w.Spec.Ingress.Hosts[0])
+	}
+	if w.Spec.Gateway != nil && len(w.Spec.Gateway.Hostnames) > 0 {
+		return fmt.Sprintf("%s://%s", scheme, w.Spec.Gateway.Hostnames[0])
+	}
+	return ""
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -646,7 +1550,7 @@ func (r *ReconcileWordpress) addFinalizer(w *examplev1.Wordpress) error {
 }
 
 func (r *ReconcileWordpress) finalizeMysqlPVC(w *examplev1.Wordpress) error {
-	pvc := r.genMysqlPVC(w)
+	pvc := r.genMysqlPVC(w, nil)
 
 	// delete PVC
 	err := r.DeleteObject(pvc, "PersistentVolumeClaim")
@@ -654,31 +1558,46 @@ func (r *ReconcileWordpress) finalizeMysqlPVC(w *examplev1.Wordpress) error {
 }
 
 func (r *ReconcileWordpress) finalizeWordpressPVC(w *examplev1.Wordpress) error {
-	pvc := r.genWordpressPVC(w)
+	pvc := r.genWordpressPVC(w, nil)
 
 	// delete PVC
 	err := r.DeleteObject(pvc, "PersistentVolumeClaim")
 	return err
 }
 
-// run finalizer for Wordpress
-func (r *ReconcileWordpress) finalizeWordpress(w *examplev1.Wordpress) error {
+// run finalizer for Wordpress, returns false while waiting for the final
+// backup of deletionPolicy Backup
+func (r *ReconcileWordpress) finalizeWordpress(w *examplev1.Wordpress) (bool, error) {
+	// remove clone objects left in the source namespace
+	if cloneInProgress(w) {
+		err := r.finalizeClone(w)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	// delete PVCs if not retaining
 	if w.Spec.RetainVolumes == false {
+		// take the final backup first
+		backedUp, err := r.finalBackup(w)
+		if err != nil || !backedUp {
+			return false, err
+		}
+
 		// delete mysql PVC
-		err := r.finalizeMysqlPVC(w)
+		err = r.finalizeMysqlPVC(w)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		// delete wordpress PVC
 		err = r.finalizeWordpressPVC(w)
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 	r.logger.Info("Successfully finalized Wordpress")
-	return nil
+	return true, nil
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -704,3 +1623,22 @@ func (r *ReconcileWordpress) updateStatus(w *examplev1.Wordpress, field string)
 	}
     }
 }
+
+// sets the <field>Created condition to False when the object is skipped, with
+// the reason and message why
+func (r *ReconcileWordpress) updateStatusNotCreated(w *examplev1.Wordpress, field string, reason string, message string) {
+	cond := condv1.Condition{
+		Type:               condv1.ConditionType(fmt.Sprintf("%sCreated", field)),
+		Status:             corev1.ConditionFalse,
+		Reason:             condv1.ConditionReason(reason),
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	if ok := w.Status.Conditions.SetCondition(cond); ok {
+		err := r.client.Status().Update(context.TODO(), w)
+		if err != nil {
+			r.logger.Error(err, "Failed to update wordpress Status")
+		}
+	}
+}
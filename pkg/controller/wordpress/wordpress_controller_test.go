@@ -0,0 +1,153 @@
+package wordpress
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeWordpressService(t *testing.T) {
+	service := func(serviceType corev1.ServiceType, nodePort int32, annotations map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "wordpress", Annotations: annotations},
+			Spec: corev1.ServiceSpec{
+				Type:  serviceType,
+				Ports: []corev1.ServicePort{{Name: "wordpress", Port: 80, NodePort: nodePort}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		existing *corev1.Service
+		service  *corev1.Service
+		want     *corev1.Service
+	}{
+		{
+			name:     "keeps the allocated node port",
+			existing: service(corev1.ServiceTypeLoadBalancer, 31380, nil),
+			service:  service(corev1.ServiceTypeLoadBalancer, 0, nil),
+			want:     service(corev1.ServiceTypeLoadBalancer, 31380, nil),
+		},
+		{
+			name:     "sets the requested node port",
+			existing: service(corev1.ServiceTypeNodePort, 31380, nil),
+			service:  service(corev1.ServiceTypeNodePort, 30080, nil),
+			want:     service(corev1.ServiceTypeNodePort, 30080, nil),
+		},
+		{
+			name:     "drops the node port of a ClusterIP Service",
+			existing: service(corev1.ServiceTypeLoadBalancer, 31380, nil),
+			service:  service(corev1.ServiceTypeClusterIP, 0, nil),
+			want:     service(corev1.ServiceTypeClusterIP, 0, nil),
+		},
+		{
+			name: "replaces the annotations from spec.service",
+			existing: service(corev1.ServiceTypeClusterIP, 0, map[string]string{
+				"kept":                "yes",
+				"removed":             "yes",
+				"changed":             "old",
+				serviceAnnotationsKey: "changed,removed",
+			}),
+			service: service(corev1.ServiceTypeClusterIP, 0, map[string]string{
+				"changed":             "new",
+				serviceAnnotationsKey: "changed",
+			}),
+			want: service(corev1.ServiceTypeClusterIP, 0, map[string]string{
+				"kept":                "yes",
+				"changed":             "new",
+				serviceAnnotationsKey: "changed",
+			}),
+		},
+		{
+			name:     "adds annotations to a Service without any",
+			existing: service(corev1.ServiceTypeClusterIP, 0, nil),
+			service:  service(corev1.ServiceTypeClusterIP, 0, map[string]string{"a": "b", serviceAnnotationsKey: "a"}),
+			want:     service(corev1.ServiceTypeClusterIP, 0, map[string]string{"a": "b", serviceAnnotationsKey: "a"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mergeWordpressService(tt.existing, tt.service)
+			if !reflect.DeepEqual(tt.existing, tt.want) {
+				t.Errorf("mergeWordpressService() = %+v, want %+v", tt.existing, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeWordpressServiceTrafficPolicy(t *testing.T) {
+	existing := &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			Type:                  corev1.ServiceTypeLoadBalancer,
+			ClusterIP:             "10.96.0.10",
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+			HealthCheckNodePort:   32000,
+		},
+	}
+
+	tests := []struct {
+		name                string
+		serviceType         corev1.ServiceType
+		policy              corev1.ServiceExternalTrafficPolicyType
+		healthCheckNodePort int32
+	}{
+		{name: "local load balancer", serviceType: corev1.ServiceTypeLoadBalancer, policy: corev1.ServiceExternalTrafficPolicyTypeLocal, healthCheckNodePort: 32000},
+		{name: "cluster load balancer", serviceType: corev1.ServiceTypeLoadBalancer, policy: corev1.ServiceExternalTrafficPolicyTypeCluster},
+		{name: "local node port", serviceType: corev1.ServiceTypeNodePort, policy: corev1.ServiceExternalTrafficPolicyTypeLocal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := existing.DeepCopy()
+			mergeWordpressService(got, &corev1.Service{
+				Spec: corev1.ServiceSpec{Type: tt.serviceType, ExternalTrafficPolicy: tt.policy},
+			})
+			if got.Spec.ClusterIP != existing.Spec.ClusterIP {
+				t.Errorf("clusterIP = %q, want %q", got.Spec.ClusterIP, existing.Spec.ClusterIP)
+			}
+			if got.Spec.HealthCheckNodePort != tt.healthCheckNodePort {
+				t.Errorf("healthCheckNodePort = %d, want %d", got.Spec.HealthCheckNodePort, tt.healthCheckNodePort)
+			}
+		})
+	}
+}
+
+func TestMergeManagedAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		managed     map[string]string
+		want        map[string]string
+	}{
+		{
+			name:        "adds managed annotations",
+			annotations: map[string]string{"other": "kept"},
+			managed:     map[string]string{"b": "2", "a": "1"},
+			want:        map[string]string{"other": "kept", "a": "1", "b": "2", managedAnnotationsKey: "a,b"},
+		},
+		{
+			name:        "replaces previously managed annotations",
+			annotations: map[string]string{"other": "kept", "a": "1", "b": "2", managedAnnotationsKey: "a,b"},
+			managed:     map[string]string{"a": "3"},
+			want:        map[string]string{"other": "kept", "a": "3", managedAnnotationsKey: "a"},
+		},
+		{
+			name:        "removes all managed annotations",
+			annotations: map[string]string{"other": "kept", "a": "1", managedAnnotationsKey: "a"},
+			want:        map[string]string{"other": "kept"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mergeManagedAnnotations(tt.annotations, tt.managed)
+			if !reflect.DeepEqual(tt.annotations, tt.want) {
+				t.Errorf("mergeManagedAnnotations() = %v, want %v", tt.annotations, tt.want)
+			}
+		})
+	}
+}
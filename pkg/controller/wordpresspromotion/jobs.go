@@ -0,0 +1,389 @@
+package wordpresspromotion
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+	"github.com/srust/wordpress-operator/pkg/controller/wordpress"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// port the files Job listens on for the sender Job
+const filesPort = 8080
+
+// the sender Job in the source namespace is not owned by the promotion, so
+// give up on it after an hour in case the promotion is deleted mid-way
+const senderDeadlineSeconds = int64(3600)
+
+// replace the target database with the source database, keeping the target
+// site URL. The target URL is saved before the import, so a retried Job does
+// not pick up the source URL.
+const databaseScript = `set -eo pipefail
+until mysqladmin ping -h wordpress-mysql --silent; do sleep 2; done
+to_target() { MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysql -h wordpress-mysql -u root "$@"; }
+to_target <<EOF
+CREATE DATABASE IF NOT EXISTS wordpress_promotion;
+CREATE TABLE IF NOT EXISTS wordpress_promotion.siteurl AS
+  SELECT option_value FROM wordpress.wp_options WHERE option_name = 'siteurl';
+EOF
+MYSQL_PWD="$SOURCE_PASSWORD" mysqldump -h "$SOURCE_HOST" -u root --single-transaction --databases wordpress | to_target
+to_target wordpress <<EOF
+SET @old = (SELECT option_value FROM wp_options WHERE option_name = 'siteurl');
+SET @new = (SELECT option_value FROM wordpress_promotion.siteurl);
+UPDATE wp_posts SET guid = REPLACE(guid, @old, @new), post_content = REPLACE(post_content, @old, @new);
+UPDATE wp_options SET option_value = REPLACE(option_value, @old, @new) WHERE option_name IN ('siteurl', 'home');
+DROP DATABASE wordpress_promotion;
+EOF
+echo "replaced the database, keeping the site URL" > /dev/termination-log
+`
+
+// compare table checksums of the source and target databases
+const databaseDryRunScript = `set -eo pipefail
+checksums() {
+  local tables
+  tables=$(MYSQL_PWD="$2" mysql -h "$1" -u root -N -B -e "SET SESSION group_concat_max_len = 65536;
+    SELECT GROUP_CONCAT(CONCAT('wordpress.', table_name)) FROM information_schema.tables WHERE table_schema = 'wordpress'")
+  if [ -n "$tables" ] && [ "$tables" != "NULL" ]; then
+    MYSQL_PWD="$2" mysql -h "$1" -u root -N -B -e "CHECKSUM TABLE $tables"
+  fi
+}
+checksums "$SOURCE_HOST" "$SOURCE_PASSWORD" > /tmp/source
+checksums wordpress-mysql "$MYSQL_ROOT_PASSWORD" > /tmp/target
+awk -F '\t' '
+  { sub(/^wordpress\./, "", $1) }
+  FILENAME == ARGV[1] { src[$1] = $2; next }
+  { dst[$1] = $2 }
+  END {
+    for (t in src) { if (!(t in dst)) added = added " " t; else if (src[t] != dst[t]) changed = changed " " t }
+    for (t in dst) if (!(t in src)) kept = kept " " t
+    printf "tables replaced:%s\n", changed ? changed : " none"
+    printf "tables added:%s\n", added ? added : " none"
+    printf "tables only in target, left unchanged:%s\n", kept ? kept : " none"
+  }' /tmp/source /tmp/target > /dev/termination-log
+`
+
+// send the promoted directories, or their checksums for a dry run, to the
+// files Job, retrying until it is listening
+const senderScript = `cd /var/www/html
+dirs=""
+for d in $DIRS; do if [ -d "$d" ]; then dirs="$dirs $d"; fi; done
+send() {
+  if [ "$DRY_RUN" = "true" ]; then
+    if [ -n "$dirs" ]; then find $dirs -type f -exec md5sum {} +; fi
+  elif [ -n "$dirs" ]; then
+    tar cf - $dirs
+  else
+    mkdir -p /tmp/empty && tar cf - -C /tmp empty
+  fi
+}
+until send | nc -w 30 "$TARGET_HOST" 8080; do sleep 5; done
+`
+
+// replace the promoted directories with the ones received from the sender;
+// directories missing in the source are removed from the target
+const filesScript = `set -e
+cd /var/www/html
+rm -rf .promotion && mkdir .promotion
+nc -l -p 8080 | tar xf - -C .promotion
+for d in $DIRS; do
+  rm -rf "$d"
+  if [ -d ".promotion/$d" ]; then mv ".promotion/$d" "$d"; fi
+done
+rm -rf .promotion
+echo "replaced $DIRS" > /dev/termination-log
+`
+
+// compare the checksums received from the sender with the target directories
+const filesDryRunScript = `set -e
+cd /var/www/html
+nc -l -p 8080 > /tmp/source.md5
+dirs=""
+for d in $DIRS; do if [ -d "$d" ]; then dirs="$dirs $d"; fi; done
+touch /tmp/target.md5
+if [ -n "$dirs" ]; then find $dirs -type f -exec md5sum {} + > /tmp/target.md5; fi
+awk '
+  { f = substr($0, 35); split(f, p, "/"); dir = p[1] "/" p[2] }
+  FILENAME == ARGV[1] { src[f] = $1; srcdir[f] = dir; next }
+  { dst[f] = $1; dstdir[f] = dir }
+  END {
+    for (f in src) { if (!(f in dst)) added[srcdir[f]]++; else if (src[f] != dst[f]) changed[srcdir[f]]++; seen[srcdir[f]] = 1 }
+    for (f in dst) { if (!(f in src)) removed[dstdir[f]]++; seen[dstdir[f]] = 1 }
+    n = 0
+    for (d in seen) { printf "%s: %d added, %d changed, %d removed\n", d, added[d], changed[d], removed[d]; n++ }
+    if (n == 0) print "no files"
+  }' /tmp/source.md5 /tmp/target.md5 > /dev/termination-log
+`
+
+func databaseJobName(p *examplev1.WordpressPromotion) string {
+	return fmt.Sprintf("%s-database", p.Name)
+}
+
+func filesJobName(p *examplev1.WordpressPromotion) string {
+	return fmt.Sprintf("%s-files", p.Name)
+}
+
+// return the pod affinity to the wordpress pods, so a Job can mount their
+// ReadWriteOnce PVC
+func genWordpressAffinity() *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "wordpress", "tier": "frontend"},
+				},
+				TopologyKey: "kubernetes.io/hostname",
+			}},
+		},
+	}
+}
+
+// return the wordpress PVC volume and its mount
+func genWordpressVolume(readOnly bool) (corev1.Volume, corev1.VolumeMount) {
+	volume := corev1.Volume{
+		Name: "wordpress-persistent-storage",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: "wp-pv-claim",
+				ReadOnly:  readOnly,
+			},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      "wordpress-persistent-storage",
+		MountPath: "/var/www/html",
+		ReadOnly:  readOnly,
+	}
+	return volume, mount
+}
+
+// return the secret holding the root password of the source mysql
+func (r *ReconcileWordpressPromotion) genSourceSecret(p *examplev1.WordpressPromotion, source *examplev1.Wordpress) *corev1.Secret {
+	secretKey := os.Getenv("WORDPRESS_SECRET_KEY")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-source", p.Name),
+			Namespace: p.Namespace,
+		},
+		Type: "Opaque",
+	}
+	if source != nil {
+		secret.StringData = map[string]string{
+			secretKey: source.Spec.SqlRootPassword,
+		}
+	}
+
+	controllerutil.SetControllerReference(p, secret, r.scheme)
+	return secret
+}
+
+// return the Job replacing, or comparing, the target database, with the
+// mysql image and pull secrets of the target
+func (r *ReconcileWordpressPromotion) genDatabaseJob(p *examplev1.WordpressPromotion, target *examplev1.Wordpress) *batchv1.Job {
+	secretName := os.Getenv("WORDPRESS_SECRET_NAME")
+	secretKey := os.Getenv("WORDPRESS_SECRET_KEY")
+	imageName := wordpress.MysqlImage(target)
+	backoffLimit := int32(0)
+	automount := false
+
+	script := databaseScript
+	if p.Spec.DryRun {
+		script = databaseDryRunScript
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      databaseJobName(p),
+			Namespace: p.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "wordpress", "tier": "promotion"},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automount,
+					ImagePullSecrets:             target.ImagePullSecrets(os.Getenv("WORDPRESS_IMAGE_PULL_SECRETS")),
+					Containers: []corev1.Container{{
+						Image:   imageName,
+						Name:    "promote-database",
+						Command: []string{"bash", "-c", script},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "SOURCE_HOST",
+								Value: fmt.Sprintf("wordpress-mysql.%s.svc", p.Spec.Source.Namespace),
+							},
+							{
+								Name: "SOURCE_PASSWORD",
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: fmt.Sprintf("%s-source", p.Name)},
+										Key:                  secretKey,
+									},
+								},
+							},
+							{
+								Name: "MYSQL_ROOT_PASSWORD",
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+										Key:                  secretKey,
+									},
+								},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	controllerutil.SetControllerReference(p, job, r.scheme)
+	return job
+}
+
+// return the Service the sender Job streams files to
+func (r *ReconcileWordpressPromotion) genFilesService(p *examplev1.WordpressPromotion) *corev1.Service {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      filesJobName(p),
+			Namespace: p.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				"job-name": filesJobName(p),
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Port:       filesPort,
+					TargetPort: intstr.FromInt(filesPort),
+					Name:       "files",
+				},
+			},
+		},
+	}
+
+	controllerutil.SetControllerReference(p, service, r.scheme)
+	return service
+}
+
+// return the Job replacing, or comparing, the target directories. It runs
+// next to the target wordpress pod, as the PVC is ReadWriteOnce.
+func (r *ReconcileWordpressPromotion) genFilesJob(p *examplev1.WordpressPromotion, target *examplev1.Wordpress) *batchv1.Job {
+	imageName := os.Getenv("WORDPRESS_IMAGE_BUSYBOX")
+	backoffLimit := int32(0)
+	automount := false
+	volume, mount := genWordpressVolume(p.Spec.DryRun)
+
+	script := filesScript
+	if p.Spec.DryRun {
+		script = filesDryRunScript
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      filesJobName(p),
+			Namespace: p.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "wordpress", "tier": "promotion"},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automount,
+					ImagePullSecrets:             target.ImagePullSecrets(os.Getenv("WORDPRESS_IMAGE_PULL_SECRETS")),
+					Affinity:                     genWordpressAffinity(),
+					Containers: []corev1.Container{{
+						Image:   imageName,
+						Name:    "promote-files",
+						Command: []string{"sh", "-c", script},
+						Env: []corev1.EnvVar{{
+							Name:  "DIRS",
+							Value: strings.Join(promotedDirs(p), " "),
+						}},
+						Ports: []corev1.ContainerPort{{
+							ContainerPort: filesPort,
+							Name:          "files",
+						}},
+						VolumeMounts: []corev1.VolumeMount{mount},
+					}},
+					Volumes: []corev1.Volume{volume},
+				},
+			},
+		},
+	}
+
+	controllerutil.SetControllerReference(p, job, r.scheme)
+	return job
+}
+
+// return the Job in the source namespace sending the promoted directories.
+// It runs next to the source wordpress pod, as the PVC is ReadWriteOnce.
+func (r *ReconcileWordpressPromotion) genSenderJob(p *examplev1.WordpressPromotion) *batchv1.Job {
+	imageName := os.Getenv("WORDPRESS_IMAGE_BUSYBOX")
+	backoffLimit := int32(3)
+	automount := false
+	deadline := senderDeadlineSeconds
+	volume, mount := genWordpressVolume(true)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-to-%s", p.Name, p.Namespace),
+			Namespace: p.Spec.Source.Namespace,
+			Labels:    map[string]string{"app": "wordpress"},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "wordpress", "tier": "promotion"},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automount,
+					// the secrets of the target may not exist in the source namespace
+					ImagePullSecrets: examplev1.ImagePullSecrets(os.Getenv("WORDPRESS_IMAGE_PULL_SECRETS"), nil),
+					Affinity:         genWordpressAffinity(),
+					Containers: []corev1.Container{{
+						Image:   imageName,
+						Name:    "promote-files",
+						Command: []string{"sh", "-c", senderScript},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "DIRS",
+								Value: strings.Join(promotedDirs(p), " "),
+							},
+							{
+								Name:  "DRY_RUN",
+								Value: fmt.Sprintf("%t", p.Spec.DryRun),
+							},
+							{
+								Name:  "TARGET_HOST",
+								Value: fmt.Sprintf("%s.%s.svc", filesJobName(p), p.Namespace),
+							},
+						},
+						VolumeMounts: []corev1.VolumeMount{mount},
+					}},
+					Volumes: []corev1.Volume{volume},
+				},
+			},
+		},
+	}
+
+	return job
+}
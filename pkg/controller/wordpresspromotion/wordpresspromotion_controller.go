@@ -0,0 +1,509 @@
+package wordpresspromotion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_wordpresspromotion")
+
+// interval to poll the safety WordpressSnapshot, which is not owned by the promotion
+const pollInterval = 10 * time.Second
+
+// Add creates a new WordpressPromotion Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileWordpressPromotion{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("wordpresspromotion-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource WordpressPromotion
+	err = c.Watch(&source.Kind{Type: &examplev1.WordpressPromotion{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Jobs syncing the database and files
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &examplev1.WordpressPromotion{},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileWordpressPromotion implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileWordpressPromotion{}
+
+// ReconcileWordpressPromotion reconciles a WordpressPromotion object
+type ReconcileWordpressPromotion struct {
+	client client.Client
+	scheme *runtime.Scheme
+	logger logr.Logger
+}
+
+// Reconcile promotes data from a source Wordpress instance, usually a
+// staging clone, to a target Wordpress instance in another namespace:
+//   - take a WordpressSnapshot of the target as a safety backup, which
+//     briefly scales the target down
+//   - run a Job replacing the target database, keeping the target site URL
+//   - run Jobs streaming the selected wp-content directories from the
+//     source to the target
+//
+// A dry run skips the snapshot and runs Jobs comparing source and target
+// instead, reporting what would change in the status.
+func (r *ReconcileWordpressPromotion) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	r.logger = log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	r.logger.Info("Reconciling WordpressPromotion")
+
+	promotion := &examplev1.WordpressPromotion{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, promotion)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	switch promotion.Status.Phase {
+	case "":
+		return r.startPromotion(promotion)
+	case examplev1.WordpressPromotionBackingUp:
+		return r.waitForSafetySnapshot(promotion)
+	case examplev1.WordpressPromotionSyncing:
+		return reconcile.Result{}, r.waitForSync(promotion)
+	}
+
+	return reconcile.Result{}, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// Promotion phases
+/////////////////////////////////////////////////////////////////////
+
+// validate the promotion and take the safety snapshot of the target
+func (r *ReconcileWordpressPromotion) startPromotion(p *examplev1.WordpressPromotion) (reconcile.Result, error) {
+	if err := validatePromotion(p, os.Getenv("WATCH_NAMESPACE")); err != nil {
+		return reconcile.Result{}, r.fail(p, err.Error())
+	}
+
+	for _, name := range []types.NamespacedName{
+		{Namespace: p.Namespace, Name: p.Spec.TargetName},
+		{Namespace: p.Spec.Source.Namespace, Name: p.Spec.Source.Name},
+	} {
+		w := &examplev1.Wordpress{}
+		err := r.client.Get(context.TODO(), name, w)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return reconcile.Result{}, r.fail(p, fmt.Sprintf("Wordpress %s not found", name))
+			}
+			return reconcile.Result{}, err
+		}
+		if name.Namespace == p.Spec.Source.Namespace && !w.AllowsCloneTo(p.Namespace) {
+			return reconcile.Result{}, r.fail(p, sourceNotAllowedMessage(p))
+		}
+	}
+
+	if p.Spec.DryRun {
+		return reconcile.Result{}, r.startSync(p)
+	}
+
+	// the safety snapshot is not owned by the promotion, so it is kept
+	// when the promotion is deleted
+	snapshot := &examplev1.WordpressSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-safety", p.Name),
+			Namespace: p.Namespace,
+		},
+		Spec: examplev1.WordpressSnapshotSpec{
+			WordpressName: p.Spec.TargetName,
+		},
+	}
+	if err := r.CreateObject(snapshot, "WordpressSnapshot"); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	p.Status.SafetySnapshot = snapshot.Name
+	err := r.setPhase(p, examplev1.WordpressPromotionBackingUp, fmt.Sprintf("waiting for WordpressSnapshot %s", snapshot.Name))
+	return reconcile.Result{RequeueAfter: pollInterval}, err
+}
+
+// wait for the safety snapshot to be ready before syncing
+func (r *ReconcileWordpressPromotion) waitForSafetySnapshot(p *examplev1.WordpressPromotion) (reconcile.Result, error) {
+	snapshot := &examplev1.WordpressSnapshot{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: p.Namespace, Name: p.Status.SafetySnapshot}, snapshot)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, r.fail(p, fmt.Sprintf("WordpressSnapshot %s not found", p.Status.SafetySnapshot))
+		}
+		return reconcile.Result{}, err
+	}
+
+	switch snapshot.Status.Phase {
+	case examplev1.WordpressSnapshotReady:
+		return reconcile.Result{}, r.startSync(p)
+	case examplev1.WordpressSnapshotFailed:
+		return reconcile.Result{}, r.fail(p, fmt.Sprintf("WordpressSnapshot %s failed: %s", snapshot.Name, snapshot.Status.Message))
+	}
+
+	return reconcile.Result{RequeueAfter: pollInterval}, nil
+}
+
+// create the Jobs syncing, or comparing, the selected scope
+func (r *ReconcileWordpressPromotion) startSync(p *examplev1.WordpressPromotion) error {
+	source := &examplev1.Wordpress{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: p.Spec.Source.Namespace, Name: p.Spec.Source.Name}, source)
+	if err != nil {
+		return err
+	}
+	// checked again, as the source may have revoked it while the safety
+	// snapshot was taken
+	if !source.AllowsCloneTo(p.Namespace) {
+		return r.fail(p, sourceNotAllowedMessage(p))
+	}
+	target := &examplev1.Wordpress{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: p.Namespace, Name: p.Spec.TargetName}, target)
+	if err != nil {
+		return err
+	}
+
+	if hasScope(p, examplev1.WordpressPromotionDatabase) {
+		if err := r.CreateObject(r.genSourceSecret(p, source), "Secret"); err != nil {
+			return err
+		}
+		if err := r.CreateObject(r.genDatabaseJob(p, target), "Job"); err != nil {
+			return err
+		}
+	}
+
+	if len(promotedDirs(p)) > 0 {
+		if err := r.CreateObject(r.genFilesService(p), "Service"); err != nil {
+			return err
+		}
+		if err := r.CreateObject(r.genFilesJob(p, target), "Job"); err != nil {
+			return err
+		}
+		err := r.CreateObject(r.genSenderJob(p), "Job")
+		if errors.IsForbidden(err) {
+			return r.failSync(p, fmt.Sprintf("not allowed to create Jobs in namespace %s, grant the operator role there", p.Spec.Source.Namespace))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	message := "syncing"
+	if p.Spec.DryRun {
+		message = "comparing source and target"
+	}
+	return r.setPhase(p, examplev1.WordpressPromotionSyncing, message)
+}
+
+// wait for the Jobs to complete and report their results
+func (r *ReconcileWordpressPromotion) waitForSync(p *examplev1.WordpressPromotion) error {
+	jobs := []promotionJob{}
+	if hasScope(p, examplev1.WordpressPromotionDatabase) {
+		jobs = append(jobs, promotionJob{result: "database", name: databaseJobName(p)})
+	}
+	if len(promotedDirs(p)) > 0 {
+		jobs = append(jobs, promotionJob{result: "files", name: filesJobName(p)})
+	}
+
+	results := []examplev1.WordpressPromotionResult{}
+	for _, job := range jobs {
+		message, done, err := r.findJobResult(p.Namespace, job.name)
+		if err != nil {
+			if _, failed := err.(jobFailedError); failed {
+				return r.failSync(p, err.Error())
+			}
+			return err
+		}
+		if !done {
+			return nil
+		}
+		results = append(results, examplev1.WordpressPromotionResult{Name: job.result, Message: message})
+	}
+
+	if err := r.finalizeSync(p); err != nil {
+		return err
+	}
+
+	p.Status.Results = results
+	message := fmt.Sprintf("promoted %s from %s/%s", scopeString(p), p.Spec.Source.Namespace, p.Spec.Source.Name)
+	if p.Spec.DryRun {
+		message = fmt.Sprintf("dry run of promoting %s from %s/%s", scopeString(p), p.Spec.Source.Namespace, p.Spec.Source.Name)
+	}
+	return r.setPhase(p, examplev1.WordpressPromotionCompleted, message)
+}
+
+// remove the source password and the objects only needed while syncing
+func (r *ReconcileWordpressPromotion) finalizeSync(p *examplev1.WordpressPromotion) error {
+	if err := r.DeleteObject(r.genSourceSecret(p, nil), "Secret"); err != nil {
+		return err
+	}
+	if err := r.DeleteObject(r.genFilesService(p), "Service"); err != nil {
+		return err
+	}
+
+	// not owned by the promotion, as owner references cannot cross namespaces.
+	// Without access to the source namespace the Job could not have been created.
+	err := r.DeleteObject(r.genSenderJob(p), "Job")
+	if errors.IsForbidden(err) {
+		return nil
+	}
+	return err
+}
+
+/////////////////////////////////////////////////////////////////////
+// Validation and scope
+/////////////////////////////////////////////////////////////////////
+
+// check the promotion references two instances and a known scope, and the
+// operator watches the source namespace. watchNamespace is the comma
+// separated WATCH_NAMESPACE, empty to watch all namespaces.
+func validatePromotion(p *examplev1.WordpressPromotion, watchNamespace string) error {
+	if p.Spec.Source.Namespace == "" || p.Spec.Source.Namespace == p.Namespace {
+		return fmt.Errorf("source.namespace must be another namespace, only one Wordpress instance is supported per namespace")
+	}
+	if !watchesNamespace(watchNamespace, p.Spec.Source.Namespace) {
+		return fmt.Errorf("the operator does not watch namespace %s, add it to WATCH_NAMESPACE", p.Spec.Source.Namespace)
+	}
+
+	if len(p.Spec.Scope) == 0 {
+		return fmt.Errorf("scope must not be empty")
+	}
+	for _, scope := range p.Spec.Scope {
+		switch scope {
+		case examplev1.WordpressPromotionDatabase,
+			examplev1.WordpressPromotionUploads,
+			examplev1.WordpressPromotionThemes,
+			examplev1.WordpressPromotionPlugins:
+		default:
+			return fmt.Errorf("unknown scope %q, must be one of database, uploads, themes, plugins", scope)
+		}
+	}
+
+	return nil
+}
+
+// returns the message for a source Wordpress not allowing promotions to the
+// namespace of p
+func sourceNotAllowedMessage(p *examplev1.WordpressPromotion) string {
+	return fmt.Sprintf("source Wordpress %s/%s does not allow promoting to namespace %s, add it to its spec.allowedCloneNamespaces",
+		p.Spec.Source.Namespace, p.Spec.Source.Name, p.Namespace)
+}
+
+// return true if the comma separated watchNamespace includes namespace, or is
+// empty and all namespaces are watched
+func watchesNamespace(watchNamespace string, namespace string) bool {
+	if watchNamespace == "" {
+		return true
+	}
+	for _, ns := range strings.Split(watchNamespace, ",") {
+		if strings.TrimSpace(ns) == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// return true if the promotion includes scope
+func hasScope(p *examplev1.WordpressPromotion, scope examplev1.WordpressPromotionScope) bool {
+	for _, s := range p.Spec.Scope {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// return the wp-content directories included in the promotion
+func promotedDirs(p *examplev1.WordpressPromotion) []string {
+	dirs := []string{}
+	for _, scope := range []examplev1.WordpressPromotionScope{
+		examplev1.WordpressPromotionUploads,
+		examplev1.WordpressPromotionThemes,
+		examplev1.WordpressPromotionPlugins,
+	} {
+		if hasScope(p, scope) {
+			dirs = append(dirs, fmt.Sprintf("wp-content/%s", scope))
+		}
+	}
+	return dirs
+}
+
+func scopeString(p *examplev1.WordpressPromotion) string {
+	scopes := []string{}
+	for _, s := range p.Spec.Scope {
+		scopes = append(scopes, string(s))
+	}
+	return strings.Join(scopes, ", ")
+}
+
+/////////////////////////////////////////////////////////////////////
+// Jobs
+/////////////////////////////////////////////////////////////////////
+
+// promotionJob is a Job whose termination message is reported as a result
+type promotionJob struct {
+	result string
+	name   string
+}
+
+// jobFailedError is returned by findJobResult when the Job failed
+type jobFailedError struct {
+	name string
+}
+
+func (e jobFailedError) Error() string {
+	return fmt.Sprintf("Job %s failed", e.name)
+}
+
+// return the termination message of a completed Job, whether it completed,
+// or jobFailedError if it failed
+func (r *ReconcileWordpressPromotion) findJobResult(namespace string, name string) (string, bool, error) {
+	job := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, job)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return "", false, jobFailedError{name: name}
+		}
+	}
+	if job.Status.Succeeded == 0 {
+		return "", false, nil
+	}
+
+	pods := &corev1.PodList{}
+	err = r.client.List(context.TODO(), pods, client.InNamespace(namespace), client.MatchingLabels{"job-name": name})
+	if err != nil {
+		return "", false, err
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil && status.State.Terminated.ExitCode == 0 {
+				return strings.TrimSpace(status.State.Terminated.Message), true, nil
+			}
+		}
+	}
+	return "", true, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// Objects
+/////////////////////////////////////////////////////////////////////
+
+// CreateObject creates an object
+func (r *ReconcileWordpressPromotion) CreateObject(obj client.Object, kind string) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		r.logger.Error(err, "failed to get meta information", "Kind", kind)
+		return err
+	}
+
+	err = r.client.Create(context.TODO(), obj)
+	if err == nil {
+		r.logger.Info("created object", "Kind", kind, "Name", accessor.GetName())
+	} else {
+		if errors.IsAlreadyExists(err) {
+			return nil
+		}
+		r.logger.Error(err, "failed to create object", "Kind", kind, "Name", accessor.GetName())
+		return err
+	}
+
+	return nil
+}
+
+// DeleteObject deletes an object and its dependents
+func (r *ReconcileWordpressPromotion) DeleteObject(obj client.Object, kind string) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		r.logger.Error(err, "failed to get meta information", "Kind", kind)
+		return err
+	}
+
+	err = r.client.Delete(context.TODO(), obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err == nil {
+		r.logger.Info("deleted object", "Kind", kind, "Name", accessor.GetName())
+		return nil
+	} else if errors.IsNotFound(err) {
+		return nil
+	} else {
+		r.logger.Error(err, "failed to delete object", "Kind", kind, "Name", accessor.GetName())
+		return err
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// WordpressPromotion Status
+/////////////////////////////////////////////////////////////////////
+
+func (r *ReconcileWordpressPromotion) setPhase(p *examplev1.WordpressPromotion, phase examplev1.WordpressPromotionPhase, message string) error {
+	p.Status.Phase = phase
+	p.Status.Message = message
+
+	err := r.client.Status().Update(context.TODO(), p)
+	if err != nil {
+		r.logger.Error(err, "Failed to update WordpressPromotion Status")
+	}
+	return err
+}
+
+func (r *ReconcileWordpressPromotion) fail(p *examplev1.WordpressPromotion, message string) error {
+	r.logger.Info("promotion failed", "Message", message)
+	return r.setPhase(p, examplev1.WordpressPromotionFailed, message)
+}
+
+// fail once syncing started, the Jobs may have partially replaced the
+// target, so the message names the safety snapshot to restore it from
+func (r *ReconcileWordpressPromotion) failSync(p *examplev1.WordpressPromotion, message string) error {
+	r.finalizeSync(p)
+	if !p.Spec.DryRun && p.Status.SafetySnapshot != "" {
+		message = fmt.Sprintf("%s; the target may be partially promoted, restore it by recreating Wordpress %s with fromSnapshot %s",
+			message, p.Spec.TargetName, p.Status.SafetySnapshot)
+	}
+	return r.fail(p, message)
+}
@@ -0,0 +1,267 @@
+package wordpresspromotion
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestValidatePromotion(t *testing.T) {
+	tests := []struct {
+		name           string
+		source         string
+		scope          []examplev1.WordpressPromotionScope
+		watchNamespace string
+		wantErr        bool
+	}{
+		{name: "valid", source: "staging", scope: []examplev1.WordpressPromotionScope{examplev1.WordpressPromotionDatabase}},
+		{name: "watched namespaces", source: "staging", scope: []examplev1.WordpressPromotionScope{examplev1.WordpressPromotionThemes}, watchNamespace: "production, staging"},
+		{name: "unwatched source namespace", source: "staging", scope: []examplev1.WordpressPromotionScope{examplev1.WordpressPromotionThemes}, watchNamespace: "production", wantErr: true},
+		{name: "same namespace", source: "production", scope: []examplev1.WordpressPromotionScope{examplev1.WordpressPromotionDatabase}, wantErr: true},
+		{name: "no source namespace", scope: []examplev1.WordpressPromotionScope{examplev1.WordpressPromotionDatabase}, wantErr: true},
+		{name: "empty scope", source: "staging", wantErr: true},
+		{name: "unknown scope", source: "staging", scope: []examplev1.WordpressPromotionScope{"media"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &examplev1.WordpressPromotion{
+				ObjectMeta: metav1.ObjectMeta{Name: "release-1", Namespace: "production"},
+				Spec: examplev1.WordpressPromotionSpec{
+					Source:     examplev1.WordpressReference{Name: "mysite", Namespace: tt.source},
+					TargetName: "mysite",
+					Scope:      tt.scope,
+				},
+			}
+			err := validatePromotion(p, tt.watchNamespace)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePromotion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// returns a reconciler with a fake client holding the target and source
+// Wordpress instances, the source allowing promotions to allowed
+func testReconciler(t *testing.T, allowed []string, objs ...client.Object) *ReconcileWordpressPromotion {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := examplev1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	objs = append(objs,
+		&examplev1.Wordpress{ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"}},
+		&examplev1.Wordpress{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "staging"},
+			Spec:       examplev1.WordpressSpec{AllowedCloneNamespaces: allowed},
+		},
+	)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	return &ReconcileWordpressPromotion{client: c, scheme: scheme, logger: log}
+}
+
+func testPromotion(dryRun bool) *examplev1.WordpressPromotion {
+	return &examplev1.WordpressPromotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "release-1", Namespace: "production"},
+		Spec: examplev1.WordpressPromotionSpec{
+			Source:     examplev1.WordpressReference{Name: "mysite", Namespace: "staging"},
+			TargetName: "mysite",
+			Scope:      []examplev1.WordpressPromotionScope{examplev1.WordpressPromotionDatabase},
+			DryRun:     dryRun,
+		},
+	}
+}
+
+// reconciles the promotion and returns it as stored
+func reconcilePromotion(t *testing.T, r *ReconcileWordpressPromotion) *examplev1.WordpressPromotion {
+	key := types.NamespacedName{Namespace: "production", Name: "release-1"}
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	p := &examplev1.WordpressPromotion{}
+	if err := r.client.Get(context.TODO(), key, p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// returns whether the object named name exists in the production namespace
+func exists(t *testing.T, r *ReconcileWordpressPromotion, obj client.Object, name string) bool {
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "production", Name: name}, obj)
+	if err != nil && !errors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestStartPromotion(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowed      []string
+		dryRun       bool
+		wantPhase    examplev1.WordpressPromotionPhase
+		wantSnapshot bool
+		wantJob      bool
+	}{
+		{name: "source not allowing promotions", wantPhase: examplev1.WordpressPromotionFailed},
+		{name: "source allowing other namespaces", allowed: []string{"preview"}, wantPhase: examplev1.WordpressPromotionFailed},
+		{name: "promotion", allowed: []string{"production"}, wantPhase: examplev1.WordpressPromotionBackingUp, wantSnapshot: true},
+		{name: "dry run", allowed: []string{"production"}, dryRun: true, wantPhase: examplev1.WordpressPromotionSyncing, wantJob: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconciler(t, tt.allowed, testPromotion(tt.dryRun))
+			p := reconcilePromotion(t, r)
+
+			if p.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %s %q, want %s", p.Status.Phase, p.Status.Message, tt.wantPhase)
+			}
+			if tt.wantPhase == examplev1.WordpressPromotionFailed && !strings.Contains(p.Status.Message, "allowedCloneNamespaces") {
+				t.Errorf("message = %q, want the opt-in field", p.Status.Message)
+			}
+			if found := exists(t, r, &examplev1.WordpressSnapshot{}, "release-1-safety"); found != tt.wantSnapshot {
+				t.Errorf("safety snapshot found = %v, want %v", found, tt.wantSnapshot)
+			}
+			if found := exists(t, r, &corev1.Secret{}, "release-1-source"); found != tt.wantJob {
+				t.Errorf("source secret found = %v, want %v", found, tt.wantJob)
+			}
+			if found := exists(t, r, &batchv1.Job{}, "release-1-database"); found != tt.wantJob {
+				t.Errorf("database Job found = %v, want %v", found, tt.wantJob)
+			}
+		})
+	}
+}
+
+func TestPromotionPhases(t *testing.T) {
+	tests := []struct {
+		name          string
+		snapshotPhase examplev1.WordpressSnapshotPhase
+		revoke        bool
+		jobFailed     bool
+		wantPhase     examplev1.WordpressPromotionPhase
+		wantMessage   string
+	}{
+		{
+			name:          "safety snapshot failed",
+			snapshotPhase: examplev1.WordpressSnapshotFailed,
+			wantPhase:     examplev1.WordpressPromotionFailed,
+			wantMessage:   "WordpressSnapshot release-1-safety failed: quiesce timed out",
+		},
+		{
+			name:          "revoked while backing up",
+			snapshotPhase: examplev1.WordpressSnapshotReady,
+			revoke:        true,
+			wantPhase:     examplev1.WordpressPromotionFailed,
+			wantMessage:   "source Wordpress staging/mysite does not allow promoting to namespace production, add it to its spec.allowedCloneNamespaces",
+		},
+		{
+			name:          "database Job failed",
+			snapshotPhase: examplev1.WordpressSnapshotReady,
+			jobFailed:     true,
+			wantPhase:     examplev1.WordpressPromotionFailed,
+			wantMessage:   "Job release-1-database failed; the target may be partially promoted, restore it by recreating Wordpress mysite with fromSnapshot release-1-safety",
+		},
+		{
+			name:          "completed",
+			snapshotPhase: examplev1.WordpressSnapshotReady,
+			wantPhase:     examplev1.WordpressPromotionCompleted,
+			wantMessage:   "promoted database from staging/mysite",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconciler(t, []string{"production"}, testPromotion(false))
+			if p := reconcilePromotion(t, r); p.Status.Phase != examplev1.WordpressPromotionBackingUp {
+				t.Fatalf("phase = %s %q, want BackingUp", p.Status.Phase, p.Status.Message)
+			}
+
+			snapshot := &examplev1.WordpressSnapshot{}
+			exists(t, r, snapshot, "release-1-safety")
+			snapshot.Status.Phase = tt.snapshotPhase
+			snapshot.Status.Message = "quiesce timed out"
+			if err := r.client.Status().Update(context.TODO(), snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if tt.revoke {
+				source := &examplev1.Wordpress{}
+				if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "staging", Name: "mysite"}, source); err != nil {
+					t.Fatal(err)
+				}
+				source.Spec.AllowedCloneNamespaces = nil
+				if err := r.client.Update(context.TODO(), source); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			p := reconcilePromotion(t, r)
+			if p.Status.Phase == examplev1.WordpressPromotionSyncing {
+				job := &batchv1.Job{}
+				exists(t, r, job, "release-1-database")
+				job.Status.Succeeded = 1
+				if tt.jobFailed {
+					job.Status.Succeeded = 0
+					job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+				}
+				if err := r.client.Status().Update(context.TODO(), job); err != nil {
+					t.Fatal(err)
+				}
+				p = reconcilePromotion(t, r)
+			}
+
+			if p.Status.Phase != tt.wantPhase || p.Status.Message != tt.wantMessage {
+				t.Errorf("phase = %s %q, want %s %q", p.Status.Phase, p.Status.Message, tt.wantPhase, tt.wantMessage)
+			}
+			// the source password is removed once the promotion is over
+			if exists(t, r, &corev1.Secret{}, "release-1-source") {
+				t.Errorf("source secret found after the promotion")
+			}
+		})
+	}
+}
+
+func TestGenJobsOfTarget(t *testing.T) {
+	t.Setenv("WORDPRESS_IMAGE_MYSQL", "mysql:5.6")
+	t.Setenv("WORDPRESS_IMAGE_PULL_SECRETS", "operator-registry")
+	target := &examplev1.Wordpress{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: "production"},
+		Spec: examplev1.WordpressSpec{
+			PinImageDigests:  true,
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "site-registry"}},
+		},
+		Status: examplev1.WordpressStatus{Images: &examplev1.WordpressImagesStatus{Mysql: "mysql:5.6@sha256:abc"}},
+	}
+	wantSecrets := []corev1.LocalObjectReference{{Name: "operator-registry"}, {Name: "site-registry"}}
+
+	r := testReconciler(t, nil)
+	p := testPromotion(false)
+
+	database := r.genDatabaseJob(p, target).Spec.Template.Spec
+	if image := database.Containers[0].Image; image != "mysql:5.6@sha256:abc" {
+		t.Errorf("database image = %q, want the pinned mysql image of the target", image)
+	}
+	if !reflect.DeepEqual(database.ImagePullSecrets, wantSecrets) {
+		t.Errorf("database imagePullSecrets = %v, want %v", database.ImagePullSecrets, wantSecrets)
+	}
+	files := r.genFilesJob(p, target).Spec.Template.Spec
+	if !reflect.DeepEqual(files.ImagePullSecrets, wantSecrets) {
+		t.Errorf("files imagePullSecrets = %v, want %v", files.ImagePullSecrets, wantSecrets)
+	}
+}
@@ -0,0 +1,437 @@
+package wordpresssnapshot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+	"github.com/srust/wordpress-operator/pkg/controller/wordpress"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_wordpresssnapshot")
+
+// VolumeSnapshots are handled as unstructured objects, so the operator does
+// not depend on the external-snapshotter client and still starts on clusters
+// without the snapshot CRDs installed.
+var volumeSnapshotGVK = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+const (
+	// interval to poll mysql pods and VolumeSnapshots while a snapshot is in progress
+	pollInterval = 5 * time.Second
+
+	// time for the VolumeSnapshots to be ready to use by default
+	defaultTimeout = 30 * time.Minute
+)
+
+// Add creates a new WordpressSnapshot Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileWordpressSnapshot{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("wordpresssnapshot-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource WordpressSnapshot. Progress of the
+	// VolumeSnapshots is polled, as their CRDs may not be installed.
+	err = c.Watch(&source.Kind{Type: &examplev1.WordpressSnapshot{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileWordpressSnapshot implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileWordpressSnapshot{}
+
+// ReconcileWordpressSnapshot reconciles a WordpressSnapshot object
+type ReconcileWordpressSnapshot struct {
+	client client.Client
+	scheme *runtime.Scheme
+	logger logr.Logger
+}
+
+// Reconcile takes a consistent snapshot of both PVCs of a Wordpress instance:
+//   - wait for earlier snapshots of the same Wordpress to complete
+//   - check the VolumeSnapshot CRD is served
+//   - scale the mysql and wordpress Deployments down and wait for their pods to terminate
+//   - create a VolumeSnapshot for the mysql and wordpress PVCs
+//   - scale mysql and wordpress back up once both snapshots have been cut
+//   - wait for both VolumeSnapshots to be ready to use, until spec.timeout
+//
+// Ready and Failed are final, a failed snapshot is not retried. A snapshot
+// failing while it holds mysql and wordpress scaled down scales them back up.
+func (r *ReconcileWordpressSnapshot) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	r.logger = log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	r.logger.Info("Reconciling WordpressSnapshot")
+
+	snapshot := &examplev1.WordpressSnapshot{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, snapshot)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	switch snapshot.Status.Phase {
+	case "", examplev1.WordpressSnapshotPending:
+		return r.startSnapshot(snapshot)
+	case examplev1.WordpressSnapshotQuiescing:
+		return r.quiesce(snapshot)
+	case examplev1.WordpressSnapshotSnapshotting:
+		return r.waitForSnapshots(snapshot)
+	}
+
+	return reconcile.Result{}, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// Snapshot phases
+/////////////////////////////////////////////////////////////////////
+
+// check the Wordpress instance exists, no other snapshot of it is in
+// progress and VolumeSnapshots are served, record the wordpress replicas and
+// start quiescing
+func (r *ReconcileWordpressSnapshot) startSnapshot(s *examplev1.WordpressSnapshot) (reconcile.Result, error) {
+	w := &examplev1.Wordpress{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: s.Namespace, Name: s.Spec.WordpressName}, w)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, r.fail(s, fmt.Sprintf("Wordpress %s not found", s.Spec.WordpressName))
+		}
+		return reconcile.Result{}, err
+	}
+
+	snapshots := &examplev1.WordpressSnapshotList{}
+	err = r.client.List(context.TODO(), snapshots, client.InNamespace(s.Namespace))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if blocking := blockingSnapshot(s, snapshots.Items); blocking != nil {
+		message := fmt.Sprintf("waiting for WordpressSnapshot %s to complete", blocking.Name)
+		if s.Status.Phase == examplev1.WordpressSnapshotPending && s.Status.Message == message {
+			return reconcile.Result{RequeueAfter: pollInterval}, nil
+		}
+		return reconcile.Result{RequeueAfter: pollInterval}, r.setPhase(s, examplev1.WordpressSnapshotPending, message)
+	}
+
+	// check before taking the site down, the snapshot can't be taken without the CRD
+	_, err = r.client.RESTMapper().RESTMapping(volumeSnapshotGVK.GroupKind(), volumeSnapshotGVK.Version)
+	if meta.IsNoMatchError(err) {
+		return reconcile.Result{}, r.fail(s, "VolumeSnapshots are not served, install the snapshot.storage.k8s.io CRDs")
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	deployment, err := r.getDeployment(s.Namespace, wordpressDeployment)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if deployment != nil {
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		s.Status.WordpressReplicas = &replicas
+	}
+
+	// recorded before scaling down, so that a failure always scales back up
+	now := metav1.Now()
+	s.Status.StartTime = &now
+	s.Status.Quiesced = true
+	return reconcile.Result{Requeue: true}, r.setPhase(s, examplev1.WordpressSnapshotQuiescing, "waiting for mysql and wordpress to stop")
+}
+
+// scale mysql and wordpress down and wait for their pods to terminate, then
+// create the VolumeSnapshots
+func (r *ReconcileWordpressSnapshot) quiesce(s *examplev1.WordpressSnapshot) (reconcile.Result, error) {
+	if timedOut(s, time.Now()) {
+		return reconcile.Result{}, r.fail(s, fmt.Sprintf("mysql and wordpress didn't stop within %s", snapshotTimeout(s)))
+	}
+
+	if err := r.scaleDeployment(s.Namespace, mysqlDeployment, 0); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.scaleDeployment(s.Namespace, wordpressDeployment, 0); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	for _, labels := range []map[string]string{wordpress.MysqlLabels(), wordpress.WordpressLabels()} {
+		pods := &corev1.PodList{}
+		err := r.client.List(context.TODO(), pods, client.InNamespace(s.Namespace), client.MatchingLabels(labels))
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if len(pods.Items) > 0 {
+			return reconcile.Result{RequeueAfter: pollInterval}, nil
+		}
+	}
+
+	s.Status.MysqlVolumeSnapshot = fmt.Sprintf("%s-mysql", s.Name)
+	s.Status.WordpressVolumeSnapshot = fmt.Sprintf("%s-wordpress", s.Name)
+
+	for _, volume := range []struct{ name, pvc string }{
+		{s.Status.MysqlVolumeSnapshot, "mysql-pv-claim"},
+		{s.Status.WordpressVolumeSnapshot, "wp-pv-claim"},
+	} {
+		err := r.createVolumeSnapshot(s, volume.name, volume.pvc)
+		if rejected(err) {
+			return reconcile.Result{}, r.fail(s, fmt.Sprintf("VolumeSnapshot %s can't be created: %v", volume.name, err))
+		} else if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{RequeueAfter: pollInterval}, r.setPhase(s, examplev1.WordpressSnapshotSnapshotting, "waiting for VolumeSnapshots")
+}
+
+// scale mysql and wordpress back up once both snapshots are cut and wait until they are ready to use
+func (r *ReconcileWordpressSnapshot) waitForSnapshots(s *examplev1.WordpressSnapshot) (reconcile.Result, error) {
+	cut := true
+	ready := true
+
+	for _, name := range []string{s.Status.MysqlVolumeSnapshot, s.Status.WordpressVolumeSnapshot} {
+		vs, err := r.getVolumeSnapshot(s.Namespace, name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return reconcile.Result{}, r.fail(s, fmt.Sprintf("VolumeSnapshot %s not found", name))
+			}
+			return reconcile.Result{}, err
+		}
+
+		if message, found, _ := unstructured.NestedString(vs.Object, "status", "error", "message"); found {
+			return reconcile.Result{}, r.fail(s, fmt.Sprintf("VolumeSnapshot %s failed: %s", name, message))
+		}
+
+		if _, found, _ := unstructured.NestedString(vs.Object, "status", "creationTime"); !found {
+			cut = false
+		}
+		if readyToUse, _, _ := unstructured.NestedBool(vs.Object, "status", "readyToUse"); !readyToUse {
+			ready = false
+		}
+	}
+
+	if cut && (s.Status.Quiesced || s.Status.SnapshotTime == nil) {
+		if err := r.restore(s); err != nil {
+			return reconcile.Result{}, err
+		}
+		if s.Status.SnapshotTime == nil {
+			now := metav1.Now()
+			s.Status.SnapshotTime = &now
+		}
+		if err := r.client.Status().Update(context.TODO(), s); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if !cut || !ready {
+		if timedOut(s, time.Now()) {
+			return reconcile.Result{}, r.fail(s, fmt.Sprintf("VolumeSnapshots are not ready to use after %s", snapshotTimeout(s)))
+		}
+		return reconcile.Result{RequeueAfter: pollInterval}, nil
+	}
+
+	return reconcile.Result{}, r.setPhase(s, examplev1.WordpressSnapshotReady, "VolumeSnapshots are ready to use")
+}
+
+// returns the time for the VolumeSnapshots of s to be ready to use
+func snapshotTimeout(s *examplev1.WordpressSnapshot) time.Duration {
+	if s.Spec.Timeout != nil {
+		return s.Spec.Timeout.Duration
+	}
+	return defaultTimeout
+}
+
+// returns whether s is not complete at now, after its timeout
+func timedOut(s *examplev1.WordpressSnapshot, now time.Time) bool {
+	return s.Status.StartTime != nil && now.After(s.Status.StartTime.Add(snapshotTimeout(s)))
+}
+
+// return the snapshot of the same Wordpress that must complete before s may
+// start, or nil. Snapshots in progress block s, and so do waiting snapshots
+// created before s, so that the order doesn't depend on the cache.
+func blockingSnapshot(s *examplev1.WordpressSnapshot, snapshots []examplev1.WordpressSnapshot) *examplev1.WordpressSnapshot {
+	for i := range snapshots {
+		other := &snapshots[i]
+		if other.Name == s.Name || other.Spec.WordpressName != s.Spec.WordpressName {
+			continue
+		}
+
+		switch other.Status.Phase {
+		case examplev1.WordpressSnapshotQuiescing, examplev1.WordpressSnapshotSnapshotting:
+			return other
+		case "", examplev1.WordpressSnapshotPending:
+			if other.CreationTimestamp.Before(&s.CreationTimestamp) ||
+				(other.CreationTimestamp.Equal(&s.CreationTimestamp) && other.Name < s.Name) {
+				return other
+			}
+		}
+	}
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// Mysql and Wordpress Deployments
+/////////////////////////////////////////////////////////////////////
+
+// names of the Deployments, see genMysqlDeployment and genWordpressDeployment
+const (
+	mysqlDeployment     = "wordpress-mysql"
+	wordpressDeployment = "wordpress"
+)
+
+// return a Deployment, or nil if it doesn't exist
+func (r *ReconcileWordpressSnapshot) getDeployment(namespace string, name string) (*appsv1.Deployment, error) {
+	deployment := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, deployment)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// set the replicas of a Deployment
+func (r *ReconcileWordpressSnapshot) scaleDeployment(namespace string, name string, replicas int32) error {
+	deployment, err := r.getDeployment(namespace, name)
+	if err != nil || deployment == nil {
+		return err
+	}
+
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == replicas {
+		return nil
+	}
+
+	r.logger.Info("scaling deployment", "Name", name, "Replicas", replicas)
+	deployment.Spec.Replicas = &replicas
+	return r.client.Update(context.TODO(), deployment)
+}
+
+// scale mysql and wordpress back to the replicas they had before quiescing,
+// if s scaled them down. Deployments s didn't scale down are left alone, they
+// may be held by another snapshot. The caller saves the status.
+func (r *ReconcileWordpressSnapshot) restore(s *examplev1.WordpressSnapshot) error {
+	if !s.Status.Quiesced {
+		return nil
+	}
+	if err := r.scaleDeployment(s.Namespace, mysqlDeployment, 1); err != nil {
+		return err
+	}
+	if s.Status.WordpressReplicas != nil {
+		if err := r.scaleDeployment(s.Namespace, wordpressDeployment, *s.Status.WordpressReplicas); err != nil {
+			return err
+		}
+	}
+	s.Status.Quiesced = false
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// VolumeSnapshots
+/////////////////////////////////////////////////////////////////////
+
+// create a VolumeSnapshot of a PVC owned by the WordpressSnapshot
+func (r *ReconcileWordpressSnapshot) createVolumeSnapshot(s *examplev1.WordpressSnapshot, name string, pvcName string) error {
+	vs := &unstructured.Unstructured{}
+	vs.SetGroupVersionKind(volumeSnapshotGVK)
+	vs.SetName(name)
+	vs.SetNamespace(s.Namespace)
+
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvcName,
+		},
+	}
+	if s.Spec.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = s.Spec.VolumeSnapshotClassName
+	}
+	vs.Object["spec"] = spec
+
+	controllerutil.SetControllerReference(s, vs, r.scheme)
+
+	err := r.client.Create(context.TODO(), vs)
+	if err == nil {
+		r.logger.Info("created object", "Kind", "VolumeSnapshot", "Name", name)
+	} else if !errors.IsAlreadyExists(err) {
+		r.logger.Error(err, "failed to create object", "Kind", "VolumeSnapshot", "Name", name)
+		return err
+	}
+	return nil
+}
+
+// returns whether creating a VolumeSnapshot failed for good: its CRD is not
+// served, the operator may not create it or the spec is invalid
+func rejected(err error) bool {
+	return meta.IsNoMatchError(err) || errors.IsForbidden(err) || errors.IsInvalid(err)
+}
+
+// return a VolumeSnapshot
+func (r *ReconcileWordpressSnapshot) getVolumeSnapshot(namespace string, name string) (*unstructured.Unstructured, error) {
+	vs := &unstructured.Unstructured{}
+	vs.SetGroupVersionKind(volumeSnapshotGVK)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, vs)
+	return vs, err
+}
+
+/////////////////////////////////////////////////////////////////////
+// WordpressSnapshot Status
+/////////////////////////////////////////////////////////////////////
+
+func (r *ReconcileWordpressSnapshot) setPhase(s *examplev1.WordpressSnapshot, phase examplev1.WordpressSnapshotPhase, message string) error {
+	s.Status.Phase = phase
+	s.Status.Message = message
+
+	err := r.client.Status().Update(context.TODO(), s)
+	if err != nil {
+		r.logger.Error(err, "Failed to update WordpressSnapshot Status")
+	}
+	return err
+}
+
+// bring mysql and wordpress back up if s scaled them down and mark the
+// snapshot as failed. Failed is final, the deployments are not scaled again.
+func (r *ReconcileWordpressSnapshot) fail(s *examplev1.WordpressSnapshot, message string) error {
+	r.logger.Info("snapshot failed", "Message", message)
+	if err := r.restore(s); err != nil {
+		return err
+	}
+	return r.setPhase(s, examplev1.WordpressSnapshotFailed, message)
+}
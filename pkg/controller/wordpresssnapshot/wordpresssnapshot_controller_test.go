@@ -0,0 +1,491 @@
+package wordpresssnapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+	"github.com/srust/wordpress-operator/pkg/controller/wordpress"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestBlockingSnapshot(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(earlier.Add(time.Minute))
+
+	snapshot := func(name string, wordpress string, created metav1.Time, phase examplev1.WordpressSnapshotPhase) examplev1.WordpressSnapshot {
+		return examplev1.WordpressSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: created},
+			Spec:       examplev1.WordpressSnapshotSpec{WordpressName: wordpress},
+			Status:     examplev1.WordpressSnapshotStatus{Phase: phase},
+		}
+	}
+
+	s := snapshot("b", "mysite", later, "")
+
+	tests := []struct {
+		name      string
+		snapshots []examplev1.WordpressSnapshot
+		want      string
+	}{
+		{
+			name:      "only snapshot",
+			snapshots: []examplev1.WordpressSnapshot{s},
+		},
+		{
+			name:      "quiescing snapshot",
+			snapshots: []examplev1.WordpressSnapshot{s, snapshot("c", "mysite", later, examplev1.WordpressSnapshotQuiescing)},
+			want:      "c",
+		},
+		{
+			name:      "snapshotting snapshot",
+			snapshots: []examplev1.WordpressSnapshot{snapshot("a", "mysite", later, examplev1.WordpressSnapshotSnapshotting), s},
+			want:      "a",
+		},
+		{
+			name:      "snapshot of another wordpress",
+			snapshots: []examplev1.WordpressSnapshot{snapshot("a", "other", earlier, examplev1.WordpressSnapshotQuiescing), s},
+		},
+		{
+			name: "completed snapshots",
+			snapshots: []examplev1.WordpressSnapshot{
+				snapshot("a", "mysite", earlier, examplev1.WordpressSnapshotReady),
+				snapshot("c", "mysite", earlier, examplev1.WordpressSnapshotFailed),
+				s,
+			},
+		},
+		{
+			name:      "earlier waiting snapshot",
+			snapshots: []examplev1.WordpressSnapshot{snapshot("c", "mysite", earlier, examplev1.WordpressSnapshotPending), s},
+			want:      "c",
+		},
+		{
+			name:      "same time, earlier name",
+			snapshots: []examplev1.WordpressSnapshot{snapshot("a", "mysite", later, ""), s},
+			want:      "a",
+		},
+		{
+			name:      "same time, later name",
+			snapshots: []examplev1.WordpressSnapshot{s, snapshot("c", "mysite", later, "")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := blockingSnapshot(&s, tt.snapshots)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("blockingSnapshot() = %s, want nil", got.Name)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.want {
+				t.Errorf("blockingSnapshot() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// envtest
+/////////////////////////////////////////////////////////////////////
+
+// start an API server with the operator and VolumeSnapshot CRDs, skip the
+// test if the envtest binaries are not installed
+func startTestEnv(t *testing.T) (client.Client, *runtime.Scheme, func()) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		if _, err := os.Stat("/usr/local/kubebuilder/bin/kube-apiserver"); err != nil {
+			t.Skip("envtest binaries not found, set KUBEBUILDER_ASSETS to run this test")
+		}
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths: []string{filepath.Join("..", "..", "..", "deploy", "crds"), "testdata"},
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := examplev1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		env.Stop()
+		t.Fatal(err)
+	}
+	return c, scheme, func() { env.Stop() }
+}
+
+// create a namespace with a Wordpress, its mysql and wordpress Deployments,
+// and a WordpressSnapshot of it
+func createSnapshotFixture(t *testing.T, c client.Client, namespace string, wordpressReplicas int32) *examplev1.WordpressSnapshot {
+	ctx := context.TODO()
+
+	objs := []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+		&examplev1.Wordpress{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: namespace},
+			Spec:       examplev1.WordpressSpec{SqlRootPassword: "password"},
+		},
+		testDeployment(namespace, mysqlDeployment, wordpress.MysqlLabels(), 1),
+		testDeployment(namespace, wordpressDeployment, wordpress.WordpressLabels(), wordpressReplicas),
+	}
+	for _, obj := range objs {
+		if err := c.Create(ctx, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &examplev1.WordpressSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysite-snapshot", Namespace: namespace},
+		Spec:       examplev1.WordpressSnapshotSpec{WordpressName: "mysite"},
+	}
+	if err := c.Create(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testDeployment(namespace string, name string, labels map[string]string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: name, Image: "busybox"}},
+				},
+			},
+		},
+	}
+}
+
+// reconcile the snapshot once and return it
+func reconcileSnapshot(t *testing.T, r *ReconcileWordpressSnapshot, s *examplev1.WordpressSnapshot) *examplev1.WordpressSnapshot {
+	key := types.NamespacedName{Namespace: s.Namespace, Name: s.Name}
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	s = &examplev1.WordpressSnapshot{}
+	if err := r.client.Get(context.TODO(), key, s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func deploymentReplicas(t *testing.T, c client.Client, namespace string, name string) int32 {
+	deployment := &appsv1.Deployment{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	return *deployment.Spec.Replicas
+}
+
+// set the status the snapshot controller would set on a VolumeSnapshot
+func setVolumeSnapshotStatus(t *testing.T, c client.Client, namespace string, name string, status map[string]interface{}) {
+	vs := &unstructured.Unstructured{}
+	vs.SetGroupVersionKind(volumeSnapshotGVK)
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, vs); err != nil {
+		t.Fatal(err)
+	}
+	vs.Object["status"] = status
+	if err := c.Status().Update(context.TODO(), vs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcileSnapshot(t *testing.T) {
+	c, scheme, stop := startTestEnv(t)
+	defer stop()
+	r := &ReconcileWordpressSnapshot{client: c, scheme: scheme}
+
+	t.Run("ready", func(t *testing.T) {
+		namespace := "snapshot-ready"
+		s := createSnapshotFixture(t, c, namespace, 3)
+
+		s = reconcileSnapshot(t, r, s)
+		if s.Status.Phase != examplev1.WordpressSnapshotQuiescing {
+			t.Fatalf("phase = %s, want Quiescing", s.Status.Phase)
+		}
+		if s.Status.WordpressReplicas == nil || *s.Status.WordpressReplicas != 3 {
+			t.Fatalf("wordpressReplicas = %v, want 3", s.Status.WordpressReplicas)
+		}
+
+		// without controllers no pods exist, so the volumes are snapshotted right away
+		s = reconcileSnapshot(t, r, s)
+		if s.Status.Phase != examplev1.WordpressSnapshotSnapshotting {
+			t.Fatalf("phase = %s, want Snapshotting", s.Status.Phase)
+		}
+		for _, name := range []string{mysqlDeployment, wordpressDeployment} {
+			if replicas := deploymentReplicas(t, c, namespace, name); replicas != 0 {
+				t.Errorf("%s replicas = %d, want 0 while snapshotting", name, replicas)
+			}
+		}
+
+		for _, name := range []string{s.Status.MysqlVolumeSnapshot, s.Status.WordpressVolumeSnapshot} {
+			setVolumeSnapshotStatus(t, c, namespace, name, map[string]interface{}{
+				"creationTime": "2020-01-01T00:00:00Z",
+				"readyToUse":   true,
+			})
+		}
+
+		s = reconcileSnapshot(t, r, s)
+		if s.Status.Phase != examplev1.WordpressSnapshotReady {
+			t.Fatalf("phase = %s, want Ready", s.Status.Phase)
+		}
+		if s.Status.SnapshotTime == nil {
+			t.Error("snapshotTime not set")
+		}
+		if replicas := deploymentReplicas(t, c, namespace, mysqlDeployment); replicas != 1 {
+			t.Errorf("mysql replicas = %d, want 1", replicas)
+		}
+		if replicas := deploymentReplicas(t, c, namespace, wordpressDeployment); replicas != 3 {
+			t.Errorf("wordpress replicas = %d, want 3", replicas)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		namespace := "snapshot-failed"
+		s := createSnapshotFixture(t, c, namespace, 2)
+
+		s = reconcileSnapshot(t, r, s)
+		s = reconcileSnapshot(t, r, s)
+		setVolumeSnapshotStatus(t, c, namespace, s.Status.MysqlVolumeSnapshot, map[string]interface{}{
+			"error": map[string]interface{}{"message": "driver error"},
+		})
+
+		s = reconcileSnapshot(t, r, s)
+		if s.Status.Phase != examplev1.WordpressSnapshotFailed {
+			t.Fatalf("phase = %s, want Failed", s.Status.Phase)
+		}
+		if replicas := deploymentReplicas(t, c, namespace, mysqlDeployment); replicas != 1 {
+			t.Errorf("mysql replicas = %d, want 1", replicas)
+		}
+		if replicas := deploymentReplicas(t, c, namespace, wordpressDeployment); replicas != 2 {
+			t.Errorf("wordpress replicas = %d, want 2", replicas)
+		}
+
+		// Failed is final, the deployments are not scaled again
+		deployment := &appsv1.Deployment{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: mysqlDeployment}, deployment); err != nil {
+			t.Fatal(err)
+		}
+		zero := int32(0)
+		deployment.Spec.Replicas = &zero
+		if err := c.Update(context.TODO(), deployment); err != nil {
+			t.Fatal(err)
+		}
+		reconcileSnapshot(t, r, s)
+		if replicas := deploymentReplicas(t, c, namespace, mysqlDeployment); replicas != 0 {
+			t.Errorf("mysql replicas = %d, want 0 after reconciling a Failed snapshot", replicas)
+		}
+	})
+
+	t.Run("serialized", func(t *testing.T) {
+		namespace := "snapshot-serialized"
+		first := createSnapshotFixture(t, c, namespace, 1)
+		first = reconcileSnapshot(t, r, first)
+
+		second := &examplev1.WordpressSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysite-snapshot-2", Namespace: namespace},
+			Spec:       examplev1.WordpressSnapshotSpec{WordpressName: "mysite"},
+		}
+		if err := c.Create(context.TODO(), second); err != nil {
+			t.Fatal(err)
+		}
+
+		second = reconcileSnapshot(t, r, second)
+		if second.Status.Phase != examplev1.WordpressSnapshotPending {
+			t.Fatalf("phase = %s, want Pending while %s is in progress", second.Status.Phase, first.Name)
+		}
+
+		first.Status.Phase = examplev1.WordpressSnapshotReady
+		if err := c.Status().Update(context.TODO(), first); err != nil {
+			t.Fatal(err)
+		}
+
+		second = reconcileSnapshot(t, r, second)
+		if second.Status.Phase != examplev1.WordpressSnapshotQuiescing {
+			t.Fatalf("phase = %s, want Quiescing once %s completed", second.Status.Phase, first.Name)
+		}
+	})
+}
+
+/////////////////////////////////////////////////////////////////////
+// fake client
+/////////////////////////////////////////////////////////////////////
+
+// returns a reconciler with a fake client holding a Wordpress, its mysql and
+// wordpress Deployments scaled to replicas, and a WordpressSnapshot of it.
+// VolumeSnapshots are served if served is set
+func newFakeReconciler(t *testing.T, served bool, replicas int32, snapshotOf string) (*ReconcileWordpressSnapshot, *examplev1.WordpressSnapshot) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := examplev1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	if served {
+		mapper.Add(volumeSnapshotGVK, meta.RESTScopeNamespace)
+	}
+
+	namespace := "production"
+	mysqlReplicas := int32(1)
+	if replicas == 0 {
+		mysqlReplicas = 0
+	}
+	s := &examplev1.WordpressSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysite-snapshot", Namespace: namespace},
+		Spec:       examplev1.WordpressSnapshotSpec{WordpressName: snapshotOf},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(
+		&examplev1.Wordpress{ObjectMeta: metav1.ObjectMeta{Name: "mysite", Namespace: namespace}},
+		testDeployment(namespace, mysqlDeployment, wordpress.MysqlLabels(), mysqlReplicas),
+		testDeployment(namespace, wordpressDeployment, wordpress.WordpressLabels(), replicas),
+		s,
+	).Build()
+	return &ReconcileWordpressSnapshot{client: c, scheme: scheme}, s
+}
+
+func TestReconcileSnapshotFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		served        bool
+		replicas      int32
+		snapshotOf    string
+		reconciles    int
+		expire        bool
+		wantPhase     examplev1.WordpressSnapshotPhase
+		wantMessage   string
+		wantMysql     int32
+		wantWordpress int32
+	}{
+		{
+			name:          "VolumeSnapshots not served",
+			replicas:      3,
+			snapshotOf:    "mysite",
+			reconciles:    1,
+			wantPhase:     examplev1.WordpressSnapshotFailed,
+			wantMessage:   "VolumeSnapshots are not served, install the snapshot.storage.k8s.io CRDs",
+			wantMysql:     1,
+			wantWordpress: 3,
+		},
+		{
+			// another snapshot holds the deployments scaled down
+			name:          "not quiesced by this snapshot",
+			served:        true,
+			snapshotOf:    "missing",
+			reconciles:    1,
+			wantPhase:     examplev1.WordpressSnapshotFailed,
+			wantMessage:   "Wordpress missing not found",
+			wantMysql:     0,
+			wantWordpress: 0,
+		},
+		{
+			name:          "quiesced",
+			served:        true,
+			replicas:      3,
+			snapshotOf:    "mysite",
+			reconciles:    2,
+			wantPhase:     examplev1.WordpressSnapshotSnapshotting,
+			wantMessage:   "waiting for VolumeSnapshots",
+			wantMysql:     0,
+			wantWordpress: 0,
+		},
+		{
+			name:          "timed out",
+			served:        true,
+			replicas:      3,
+			snapshotOf:    "mysite",
+			reconciles:    2,
+			expire:        true,
+			wantPhase:     examplev1.WordpressSnapshotFailed,
+			wantMessage:   "VolumeSnapshots are not ready to use after 30m0s",
+			wantMysql:     1,
+			wantWordpress: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, s := newFakeReconciler(t, tt.served, tt.replicas, tt.snapshotOf)
+			for i := 0; i < tt.reconciles; i++ {
+				s = reconcileSnapshot(t, r, s)
+			}
+			if tt.expire {
+				start := metav1.NewTime(s.Status.StartTime.Add(-31 * time.Minute))
+				s.Status.StartTime = &start
+				if err := r.client.Status().Update(context.TODO(), s); err != nil {
+					t.Fatal(err)
+				}
+				s = reconcileSnapshot(t, r, s)
+			}
+
+			if s.Status.Phase != tt.wantPhase || s.Status.Message != tt.wantMessage {
+				t.Errorf("phase = %s %q, want %s %q", s.Status.Phase, s.Status.Message, tt.wantPhase, tt.wantMessage)
+			}
+			if s.Status.Quiesced != (tt.wantPhase == examplev1.WordpressSnapshotSnapshotting) {
+				t.Errorf("quiesced = %v in phase %s", s.Status.Quiesced, s.Status.Phase)
+			}
+			if replicas := deploymentReplicas(t, r.client, s.Namespace, mysqlDeployment); replicas != tt.wantMysql {
+				t.Errorf("mysql replicas = %d, want %d", replicas, tt.wantMysql)
+			}
+			if replicas := deploymentReplicas(t, r.client, s.Namespace, wordpressDeployment); replicas != tt.wantWordpress {
+				t.Errorf("wordpress replicas = %d, want %d", replicas, tt.wantWordpress)
+			}
+		})
+	}
+}
+
+func TestRejected(t *testing.T) {
+	resource := schema.GroupResource{Group: volumeSnapshotGVK.Group, Resource: "volumesnapshots"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error"},
+		{name: "not served", err: &meta.NoKindMatchError{GroupKind: volumeSnapshotGVK.GroupKind()}, want: true},
+		{name: "forbidden", err: errors.NewForbidden(resource, "mysite-snapshot-mysql", nil), want: true},
+		{name: "invalid", err: errors.NewInvalid(volumeSnapshotGVK.GroupKind(), "mysite-snapshot-mysql", nil), want: true},
+		{name: "conflict", err: errors.NewConflict(resource, "mysite-snapshot-mysql", nil)},
+		{name: "timeout", err: errors.NewServerTimeout(resource, "create", 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rejected(tt.err); got != tt.want {
+				t.Errorf("rejected(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
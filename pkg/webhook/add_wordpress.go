@@ -0,0 +1,10 @@
+package webhook
+
+import (
+	"github.com/srust/wordpress-operator/pkg/webhook/wordpress"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to register webhooks with a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, wordpress.Add)
+}
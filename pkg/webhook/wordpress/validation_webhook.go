@@ -0,0 +1,68 @@
+package wordpress
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Path the Wordpress validation webhook is served on, referenced by deploy/webhook.yaml
+const validationPath = "/validate-wordpress"
+
+// wordpressValidator rejects invalid Wordpress specs on CREATE and UPDATE,
+// including updates of the scale subresource
+type wordpressValidator struct {
+	client  client.Client
+	decoder *admission.Decoder
+}
+
+// blank assignment to verify that wordpressValidator implements admission.Handler
+var _ admission.Handler = &wordpressValidator{}
+
+// Handle validates a Wordpress, or a Scale of a Wordpress
+func (v *wordpressValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	w := &examplev1.Wordpress{}
+
+	switch req.Kind.Kind {
+	case "Wordpress":
+		if err := v.decoder.DecodeRaw(req.Object, w); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	case "Scale":
+		scale := &autoscalingv1.Scale{}
+		if err := v.decoder.DecodeRaw(req.Object, scale); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err := v.client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, w)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if w.Spec.Wordpress == nil {
+			w.Spec.Wordpress = &examplev1.WordpressFrontendSpec{}
+		}
+		w.Spec.Wordpress.Replicas = &scale.Spec.Replicas
+	default:
+		return admission.Allowed("")
+	}
+
+	// a Wordpress that is being deleted is only updated to remove its
+	// finalizer, which must not be blocked by a spec that became invalid
+	if w.DeletionTimestamp != nil {
+		return admission.Allowed("")
+	}
+
+	if err := w.Validate(os.Getenv("WORDPRESS_RWX_STORAGE_CLASSES")); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := w.ValidatePreset(os.Getenv("WORDPRESS_PRESETS"), os.Getenv("WORDPRESS_DEFAULT_PRESET")); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,67 @@
+package wordpress
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestWordpressValidatorHandle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := examplev1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &wordpressValidator{decoder: decoder}
+
+	replicas := int32(2)
+	now := metav1.Now()
+
+	tests := []struct {
+		name    string
+		deleted bool
+		allowed bool
+	}{
+		{name: "invalid spec", deleted: false, allowed: false},
+		{name: "invalid spec being deleted", deleted: true, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// two replicas sharing a ReadWriteOnce PVC are rejected
+			w := &examplev1.Wordpress{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "example.com/v1", Kind: "Wordpress"},
+				ObjectMeta: metav1.ObjectMeta{Name: "wordpress", Namespace: "default", Finalizers: []string{"finalizer"}},
+				Spec: examplev1.WordpressSpec{
+					Wordpress: &examplev1.WordpressFrontendSpec{Replicas: &replicas},
+				},
+			}
+			if tt.deleted {
+				w.DeletionTimestamp = &now
+			}
+			raw, err := json.Marshal(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := v.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Wordpress"},
+				Operation: admissionv1.Update,
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			if resp.Allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v: %v", resp.Allowed, tt.allowed, resp.Result)
+			}
+		})
+	}
+}
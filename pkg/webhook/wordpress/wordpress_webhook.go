@@ -0,0 +1,147 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	examplev1 "github.com/srust/wordpress-operator/pkg/apis/example/v1"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var log = logf.Log.WithName("webhook_wordpress")
+
+// Path the deletion protection webhook is served on, referenced by deploy/webhook.yaml
+const deletionProtectionPath = "/validate-deletion-protection"
+
+// Annotation that allows deleting a Wordpress, or one of its PVCs, with deletionProtection enabled
+const allowDeleteAnnotation = "wordpress.example.com/allow-delete"
+
+// PVCs created by the operator for each Wordpress, see genMysqlPVC and genWordpressPVC
+var wordpressPVCs = []string{"mysql-pv-claim", "wp-pv-claim"}
+
+// Add registers the Wordpress webhooks with the Manager's webhook server.
+func Add(mgr manager.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+
+	v := &deletionValidator{
+		client:   mgr.GetClient(),
+		decoder:  decoder,
+		recorder: mgr.GetEventRecorderFor("wordpress-operator"),
+	}
+	mgr.GetWebhookServer().Register(deletionProtectionPath, &webhook.Admission{Handler: v})
+
+	wv := &wordpressValidator{
+		client:  mgr.GetClient(),
+		decoder: decoder,
+	}
+	mgr.GetWebhookServer().Register(validationPath, &webhook.Admission{Handler: wv})
+	return nil
+}
+
+// deletionValidator rejects DELETE of protected Wordpress objects and their PVCs
+type deletionValidator struct {
+	client   client.Client
+	decoder  *admission.Decoder
+	recorder record.EventRecorder
+}
+
+// blank assignment to verify that deletionValidator implements admission.Handler
+var _ admission.Handler = &deletionValidator{}
+
+// Handle validates a DELETE request for a Wordpress or a PersistentVolumeClaim
+func (v *deletionValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	switch req.Kind.Kind {
+	case "Wordpress":
+		return v.handleWordpress(ctx, req)
+	case "PersistentVolumeClaim":
+		return v.handlePVC(ctx, req)
+	}
+	return admission.Allowed("")
+}
+
+// deny deletion of a protected Wordpress unless the override annotation is set
+func (v *deletionValidator) handleWordpress(ctx context.Context, req admission.Request) admission.Response {
+	w := &examplev1.Wordpress{}
+	if err := v.decoder.DecodeRaw(req.OldObject, w); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if !w.Spec.DeletionProtection {
+		return admission.Allowed("")
+	}
+
+	if w.Annotations[allowDeleteAnnotation] == "true" {
+		v.recordOverride(w, fmt.Sprintf("Wordpress %s", w.Name), req.UserInfo.Username)
+		return admission.Allowed("deletion protection overridden")
+	}
+
+	return admission.Denied(fmt.Sprintf("Wordpress %s has deletionProtection enabled; set annotation %s=true to delete it",
+		w.Name, allowDeleteAnnotation))
+}
+
+// deny deletion of a PVC belonging to a protected Wordpress that is not itself being deleted
+func (v *deletionValidator) handlePVC(ctx context.Context, req admission.Request) admission.Response {
+	if !containsString(wordpressPVCs, req.Name) {
+		return admission.Allowed("")
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := v.decoder.DecodeRaw(req.OldObject, pvc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	list := &examplev1.WordpressList{}
+	if err := v.client.List(ctx, list, client.InNamespace(req.Namespace)); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	for i := range list.Items {
+		w := &list.Items[i]
+		// the finalizer deletes PVCs once the Wordpress deletion was admitted
+		if !w.Spec.DeletionProtection || w.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		if pvc.Annotations[allowDeleteAnnotation] == "true" {
+			v.recordOverride(w, fmt.Sprintf("PersistentVolumeClaim %s", pvc.Name), req.UserInfo.Username)
+			return admission.Allowed("deletion protection overridden")
+		}
+
+		return admission.Denied(fmt.Sprintf("PersistentVolumeClaim %s belongs to Wordpress %s which has deletionProtection enabled; set annotation %s=true to delete it",
+			pvc.Name, w.Name, allowDeleteAnnotation))
+	}
+
+	return admission.Allowed("")
+}
+
+// record an Event on the Wordpress when deletion protection was overridden
+func (v *deletionValidator) recordOverride(w *examplev1.Wordpress, object string, username string) {
+	log.Info("deletion protection overridden", "Namespace", w.Namespace, "Wordpress", w.Name, "Object", object, "User", username)
+	v.recorder.Eventf(w, corev1.EventTypeWarning, "DeletionProtectionOverridden",
+		"%s deleted by %s using annotation %s", object, username, allowDeleteAnnotation)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}